@@ -0,0 +1,175 @@
+package code
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"os"
+	"sync"
+)
+
+// PipelineVersion identifies the output format shared by all phases. Bump it
+// whenever a change (e.g. to the JSON writer) alters the files of every
+// dictionary, so results cached by older code are not reused.
+const PipelineVersion = 3
+
+// BuildCacheEntry records, for one dictionary, the inputs that produced its
+// Phase 02 and Phase 03 files and the hashes of those files.
+type BuildCacheEntry struct {
+	// Convert step (Phase 01 → 02)
+	RawHash          string `json:"raw_hash"`
+	DescriptorHash   string `json:"descriptor_hash"`
+	Converter        string `json:"converter"`
+	ConverterVersion int    `json:"converter_version"`
+	Phase02Hash      string `json:"phase02_hash"`
+
+	// HTML step (Phase 02 → 03)
+	HTMLSourceHash string `json:"html_source_hash"`
+	HTMLVersion    int    `json:"html_version"`
+	Phase03Hash    string `json:"phase03_hash"`
+}
+
+// BuildCache lets the convert and html steps skip dictionaries whose inputs have
+// not changed since the last run. It is stored as JSON in the output directory.
+// It is safe for concurrent use by the workers of a step.
+type BuildCache struct {
+	mu   sync.Mutex
+	path string
+
+	PipelineVersion int                      `json:"pipeline_version"`
+	Dictionaries    map[int]*BuildCacheEntry `json:"dictionaries"`
+}
+
+// LoadBuildCache reads the cache at path. A missing, unreadable or outdated
+// cache is not an error: it simply starts empty and every step runs.
+func LoadBuildCache(path string) *BuildCache {
+	cache := &BuildCache{
+		path:            path,
+		PipelineVersion: PipelineVersion,
+		Dictionaries:    make(map[int]*BuildCacheEntry),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			fmt.Printf("Ignoring build cache %s: %v\n", path, err)
+		}
+		return cache
+	}
+
+	var stored BuildCache
+	if err := json.Unmarshal(data, &stored); err != nil {
+		fmt.Printf("Ignoring build cache %s: %v\n", path, err)
+		return cache
+	}
+	if stored.PipelineVersion != PipelineVersion || stored.Dictionaries == nil {
+		return cache
+	}
+
+	cache.Dictionaries = stored.Dictionaries
+	return cache
+}
+
+// Save writes the cache back to disk.
+func (c *BuildCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return utils.SaveDictToJSON(c.path, c)
+}
+
+func (c *BuildCache) entry(id int) *BuildCacheEntry {
+	entry, exists := c.Dictionaries[id]
+	if !exists {
+		entry = &BuildCacheEntry{}
+		c.Dictionaries[id] = entry
+	}
+	return entry
+}
+
+// ConvertUpToDate reports whether the Phase 02 file of a dictionary was produced
+// from the same raw file, descriptor and converter version, and is still intact.
+func (c *BuildCache) ConvertUpToDate(desc modals.DictionaryDescriptor, rawHash string, converter Converter, phase02Path string) bool {
+	c.mu.Lock()
+	entry, exists := c.Dictionaries[desc.Id]
+	c.mu.Unlock()
+	if !exists || entry.Phase02Hash == "" {
+		return false
+	}
+	if entry.RawHash != rawHash || entry.DescriptorHash != descriptorHash(desc) ||
+		entry.Converter != converter.Name() || entry.ConverterVersion != converter.Version() {
+		return false
+	}
+	return fileHashMatches(phase02Path, entry.Phase02Hash)
+}
+
+// RecordConvert stores the inputs and output hash of a successful convert step.
+// Any HTML step result is kept: it is only reused if the new Phase 02 file is identical.
+func (c *BuildCache) RecordConvert(desc modals.DictionaryDescriptor, rawHash string, converter Converter, phase02Path string) error {
+	outputHash, err := utils.HashFile(phase02Path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.entry(desc.Id)
+	entry.RawHash = rawHash
+	entry.DescriptorHash = descriptorHash(desc)
+	entry.Converter = converter.Name()
+	entry.ConverterVersion = converter.Version()
+	entry.Phase02Hash = outputHash
+	return nil
+}
+
+// HTMLUpToDate reports whether the Phase 03 file of a dictionary was rendered from
+// the current Phase 02 file by the same renderer version, and is still intact.
+func (c *BuildCache) HTMLUpToDate(id int, phase02Path string, phase03Path string) bool {
+	c.mu.Lock()
+	entry, exists := c.Dictionaries[id]
+	c.mu.Unlock()
+	if !exists || entry.Phase03Hash == "" || entry.HTMLVersion != htmlRendererVersion {
+		return false
+	}
+	return fileHashMatches(phase02Path, entry.HTMLSourceHash) && fileHashMatches(phase03Path, entry.Phase03Hash)
+}
+
+// RecordHTML stores the source and output hashes of a successful html step.
+func (c *BuildCache) RecordHTML(id int, phase02Path string, phase03Path string) error {
+	sourceHash, err := utils.HashFile(phase02Path)
+	if err != nil {
+		return err
+	}
+	outputHash, err := utils.HashFile(phase03Path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.entry(id)
+	entry.HTMLSourceHash = sourceHash
+	entry.HTMLVersion = htmlRendererVersion
+	entry.Phase03Hash = outputHash
+	return nil
+}
+
+func descriptorHash(desc modals.DictionaryDescriptor) string {
+	data, _ := json.Marshal(desc)
+	return utils.HashBytes(data)
+}
+
+func fileHashMatches(filePath string, expected string) bool {
+	actual, err := utils.HashFile(filePath)
+	return err == nil && actual == expected
+}
+
+// saveBuildCache writes the cache at the end of a step. A cache that cannot be
+// written only costs a rebuild next time, so it is reported but not fatal.
+func saveBuildCache(cache *BuildCache) {
+	if err := cache.Save(); err != nil {
+		fmt.Printf("Warning: failed to save build cache: %v\n", err)
+	}
+}
@@ -0,0 +1,239 @@
+package code
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// buildInfo is the provenance of a dictionary.db, written to its build_info table.
+type buildInfo struct {
+	BuiltAt         time.Time // zero unless SOURCE_DATE_EPOCH is set
+	PipelineVersion int
+	DataVersion     string // SHA-256 of the pipeline version, the manifest and every source hash and status
+	Dictionaries    []dictionaryBuildInfo
+}
+
+// Statuses of a manifest source in build_info.
+const (
+	buildStatusMerged    = "merged"     // in the Phase 04 data
+	buildStatusFailed    = "failed"     // a step failed for it in this run
+	buildStatusSkipped   = "skipped"    // a step skipped it in this run
+	buildStatusNotMerged = "not merged" // absent from Phase 04 for a reason this run does not know
+)
+
+// dictionaryBuildInfo records where one manifest source came from, whether it made it
+// into the database and how many entries it had after each phase.
+type dictionaryBuildInfo struct {
+	Id             int
+	FileName       string
+	Status         string
+	SourceHash     string // SHA-256 of the Phase 01 file, empty if it is missing
+	Phase02Entries int
+	Phase03Entries int
+	Phase04Entries int // words of merged-database.jsonl with an entry from this dictionary
+	Phase04Phrases int // phrases of merged-phrases.jsonl with an entry from this dictionary
+}
+
+// sourceDateEpoch returns the time in SOURCE_DATE_EPOCH, or the zero time when it is
+// not set. It is the only time outputs record, so they depend on the content of their
+// inputs alone unless a date is asked for.
+func sourceDateEpoch() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %w", epoch, err)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// collectBuildInfo records every source of the manifest, merged or not, with its
+// status and its entries in the Phase 02 and 03 files. Phase 04 counts come from the
+// caller, which streams the merged files anyway.
+func collectBuildInfo(run *PipelineRun, dictionaries []modals.DictionaryInfo, phase04Entries, phase04Phrases map[int]int) (*buildInfo, error) {
+	builtAt, err := sourceDateEpoch()
+	if err != nil {
+		return nil, err
+	}
+
+	inManifest := make(map[int]bool, len(run.Manifest.Dictionaries))
+	for _, desc := range run.Manifest.Dictionaries {
+		inManifest[desc.Id] = true
+	}
+	merged := make(map[int]bool, len(dictionaries))
+	for _, d := range dictionaries {
+		if !inManifest[d.Id] {
+			return nil, fmt.Errorf("dictionary %d of dictionaries.json is not in the manifest", d.Id)
+		}
+		merged[d.Id] = true
+	}
+	descriptors := slices.SortedFunc(slices.Values(run.Manifest.Dictionaries), func(a, b modals.DictionaryDescriptor) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+
+	infos := make([]dictionaryBuildInfo, len(descriptors))
+	err = forEachDictionary(run.Config.Jobs, descriptors, func(i int, desc modals.DictionaryDescriptor) error {
+		info := dictionaryBuildInfo{
+			Id:             desc.Id,
+			FileName:       desc.FileName,
+			Status:         buildStatus(run.Summary, desc.Id, merged[desc.Id]),
+			Phase04Entries: phase04Entries[desc.Id],
+			Phase04Phrases: phase04Phrases[desc.Id],
+		}
+
+		rawPath := filepath.Join(run.Config.RawDir(), desc.FileName)
+		hash, err := utils.HashFile(rawPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		info.SourceHash = hash
+
+		fileName := Phase02FileName(desc)
+		if info.Phase02Entries, err = countPhaseEntries(filepath.Join(run.Config.JSONDir(), fileName)); err != nil {
+			return err
+		}
+		if info.Phase03Entries, err = countPhaseEntries(filepath.Join(run.Config.HTMLDir(), fileName)); err != nil {
+			return err
+		}
+
+		infos[i] = info
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manifestHash, err := utils.HashFile(run.Config.Manifest())
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash manifest: %w", err)
+	}
+	var versionInput strings.Builder
+	fmt.Fprintf(&versionInput, "pipeline %d\nmanifest %s\n", PipelineVersion, manifestHash)
+	for _, info := range infos {
+		fmt.Fprintf(&versionInput, "%d %s %s %s\n", info.Id, info.FileName, info.SourceHash, info.Status)
+	}
+
+	return &buildInfo{
+		BuiltAt:         builtAt,
+		PipelineVersion: PipelineVersion,
+		DataVersion:     utils.HashBytes([]byte(versionInput.String())),
+		Dictionaries:    infos,
+	}, nil
+}
+
+// buildStatus returns the build_info status of a manifest source.
+func buildStatus(summary *PipelineSummary, id int, merged bool) string {
+	result, known := summary.Result(id)
+	switch {
+	case known && result.Status == DictStatusFailed:
+		return buildStatusFailed
+	case merged:
+		return buildStatusMerged
+	case known && result.Status == DictStatusSkipped:
+		return buildStatusSkipped
+	default:
+		return buildStatusNotMerged
+	}
+}
+
+// countPhaseEntries returns the number of words in a Phase 02 or Phase 03 file,
+// whichever words map it holds. A missing file counts as 0.
+func countPhaseEntries(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Values are left undecoded; only the keys are counted
+	var words struct {
+		PlainText map[string]json.RawMessage `json:"words_to_plain_text_map"`
+		JsonObj   map[string]json.RawMessage `json:"words_to_json_obj_map"`
+		Html      map[string]json.RawMessage `json:"words_to_html_map"`
+	}
+	if err := json.Unmarshal(data, &words); err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return len(words.PlainText) + len(words.JsonObj) + len(words.Html), nil
+}
+
+// buildInfoRow is one key/value pair of the build_info table.
+type buildInfoRow struct {
+	key   string
+	value any
+}
+
+// writeBuildInfo creates the build_info table and fills it. Build-wide rows have a
+// NULL dictionary_id; the rows of one manifest source carry its id, which is only in
+// the dictionaries table if the source was merged.
+func writeBuildInfo(tx *sql.Tx, info *buildInfo) error {
+	_, err := tx.Exec(`
+		CREATE TABLE build_info (
+			dictionary_id INTEGER,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL
+		);
+		CREATE UNIQUE INDEX idx_build_info ON build_info(ifnull(dictionary_id, -1), key);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create build_info table: %w", err)
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO build_info (dictionary_id, key, value) VALUES (?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare build_info statement: %w", err)
+	}
+	defer stmt.Close()
+
+	insert := func(dictionaryId any, rows []buildInfoRow) error {
+		for _, row := range rows {
+			if _, err := stmt.Exec(dictionaryId, row.key, fmt.Sprint(row.value)); err != nil {
+				return fmt.Errorf("failed to insert build_info %s: %w", row.key, err)
+			}
+		}
+		return nil
+	}
+
+	rows := []buildInfoRow{
+		{"pipeline_version", info.PipelineVersion},
+		{"data_version", info.DataVersion},
+	}
+	if !info.BuiltAt.IsZero() {
+		rows = append(rows, buildInfoRow{"built_at", info.BuiltAt.Format(time.RFC3339)})
+	}
+	if err := insert(nil, rows); err != nil {
+		return err
+	}
+
+	for _, d := range info.Dictionaries {
+		err := insert(d.Id, []buildInfoRow{
+			{"file_name", d.FileName},
+			{"status", d.Status},
+			{"source_sha256", d.SourceHash},
+			{"phase02_entries", d.Phase02Entries},
+			{"phase03_entries", d.Phase03Entries},
+			{"phase04_entries", d.Phase04Entries},
+			{"phase04_phrases", d.Phase04Phrases},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package code
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"learn-circassian-helper/modals"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// headwordNumberRegex finds a homonym number at the end of a headword, e.g. "АБА1.".
+var headwordNumberRegex = regexp.MustCompile(`(\d+)([.,]?)$`)
+
+// rewriteChange is one entry of the preprocess change log.
+type rewriteChange struct {
+	Line   int
+	Rule   string
+	Before string
+	After  string
+}
+
+// rewriteRule is a RewriteRule with its regexps compiled.
+type rewriteRule struct {
+	modals.RewriteRule
+	pattern    *regexp.Regexp
+	precededBy *regexp.Regexp
+}
+
+// CallConvertPhase00ToPhase01 rebuilds the Phase 01 raw file of every selected
+// dictionary that has preprocess rules in the manifest: its content/backup file is
+// copied into phase-01-raw-data with the rules applied line by line, and every
+// rewrite is written to a change log in the reports directory.
+func CallConvertPhase00ToPhase01(run *PipelineRun) error {
+	selected, err := run.Config.SelectDictionaries(run.Manifest)
+	if err != nil {
+		return err
+	}
+
+	dictionaries := make([]modals.DictionaryDescriptor, 0)
+	for _, desc := range selected {
+		if len(desc.Preprocess) > 0 {
+			dictionaries = append(dictionaries, desc)
+		}
+	}
+
+	if err := os.MkdirAll(run.Config.RawDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.MkdirAll(run.Config.ReportsDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create reports directory: %w", err)
+	}
+
+	return forEachDictionary(run.Config.Jobs, dictionaries, func(_ int, desc modals.DictionaryDescriptor) error {
+		if err := preprocessDictionary(run.Config, desc); err != nil {
+			return run.Summary.Fail(desc, "preprocess", err)
+		}
+		run.Summary.Succeeded(desc, "preprocess")
+		return nil
+	})
+}
+
+// preprocessDictionary applies the rewrite rules of one dictionary to its backup file.
+func preprocessDictionary(config PipelineConfig, desc modals.DictionaryDescriptor) error {
+	srcFile := filepath.Join(config.BackupDir(), desc.FileName)
+	distFile := filepath.Join(config.RawDir(), desc.FileName)
+	logFile := filepath.Join(config.ReportsDir(), strings.TrimSuffix(desc.FileName, filepath.Ext(desc.FileName))+".preprocess-changes.csv")
+
+	rules, err := compileRewriteRules(desc.Preprocess)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(srcFile)
+	if err != nil {
+		return fmt.Errorf("failed to read backup file: %w", err)
+	}
+	fmt.Printf("Preprocessing into Phase 01: %s\n", srcFile)
+
+	var out strings.Builder
+	changes := make([]rewriteChange, 0)
+	for idx, line := range strings.SplitAfter(string(data), "\n") {
+		content := strings.TrimSuffix(line, "\n")
+		for _, rule := range rules {
+			content = rule.apply(content, idx+1, &changes)
+		}
+		out.WriteString(content)
+		if strings.HasSuffix(line, "\n") {
+			out.WriteString("\n")
+		}
+	}
+
+	if err := os.WriteFile(distFile, []byte(out.String()), 0644); err != nil {
+		return fmt.Errorf("failed to save %s: %w", distFile, err)
+	}
+	if err := saveRewriteChanges(logFile, changes); err != nil {
+		return fmt.Errorf("failed to save %s: %w", logFile, err)
+	}
+
+	fmt.Printf("%d rewrites in %s, see %s\n", len(changes), desc.FileName, logFile)
+	return nil
+}
+
+// compileRewriteRules compiles the regexps of a dictionary's rewrite rules. It is
+// also used to validate the manifest.
+func compileRewriteRules(rules []modals.RewriteRule) ([]rewriteRule, error) {
+	compiled := make([]rewriteRule, len(rules))
+	for i, rule := range rules {
+		if rule.Kind == "" {
+			rule.Kind = modals.RewriteKindRegex
+		}
+		if rule.Kind != modals.RewriteKindRegex && rule.Kind != modals.RewriteKindHeadword {
+			return nil, fmt.Errorf("rewrite rule %q: unknown kind %q", rule.Name, rule.Kind)
+		}
+
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rewrite rule %q: invalid pattern: %w", rule.Name, err)
+		}
+		compiled[i] = rewriteRule{RewriteRule: rule, pattern: pattern}
+
+		if rule.PrecededBy != "" {
+			precededBy, err := regexp.Compile(`^(?:` + rule.PrecededBy + `)$`)
+			if err != nil {
+				return nil, fmt.Errorf("rewrite rule %q: invalid preceded_by: %w", rule.Name, err)
+			}
+			compiled[i].precededBy = precededBy
+		}
+	}
+	return compiled, nil
+}
+
+// apply rewrites one line (without its newline) and logs the changes it made.
+func (r rewriteRule) apply(line string, lineNum int, changes *[]rewriteChange) string {
+	if r.Kind == modals.RewriteKindHeadword {
+		return r.applyHeadword(line, lineNum, changes)
+	}
+	return r.applyRegex(line, lineNum, changes)
+}
+
+func (r rewriteRule) applyRegex(line string, lineNum int, changes *[]rewriteChange) string {
+	var sb strings.Builder
+	last, pos := 0, 0
+
+	for pos <= len(line) {
+		loc := r.pattern.FindStringSubmatchIndex(line[pos:])
+		if loc == nil {
+			break
+		}
+		start, end := pos+loc[0], pos+loc[1]
+
+		// A rejected match may still have a valid one starting inside it
+		if !r.precededByMatches(line, start) || start == end {
+			_, size := utf8.DecodeRuneInString(line[start:])
+			pos = start + max(size, 1)
+			continue
+		}
+
+		after := string(r.pattern.ExpandString(nil, r.Replacement, line[pos:], loc))
+		sb.WriteString(line[last:start])
+		sb.WriteString(after)
+		*changes = append(*changes, rewriteChange{Line: lineNum, Rule: r.Name, Before: line[start:end], After: after})
+		last, pos = end, end
+	}
+
+	if last == 0 {
+		return line
+	}
+	sb.WriteString(line[last:])
+	return sb.String()
+}
+
+func (r rewriteRule) precededByMatches(line string, start int) bool {
+	if r.precededBy == nil {
+		return true
+	}
+	if start == 0 {
+		return false
+	}
+	prev, _ := utf8.DecodeLastRuneInString(line[:start])
+	return r.precededBy.MatchString(string(prev))
+}
+
+func (r rewriteRule) applyHeadword(line string, lineNum int, changes *[]rewriteChange) string {
+	loc := r.pattern.FindStringIndex(line)
+	if loc == nil {
+		return line
+	}
+	head, rest := line[:loc[0]], line[loc[0]:]
+	if strings.TrimSpace(head) == "" {
+		return line
+	}
+
+	// Remove every OCR space ("И Т У М" → "ИТУМ"), then restore the one before a homonym number
+	cleaned := strings.ReplaceAll(head, " ", "")
+	cleaned = headwordNumberRegex.ReplaceAllString(cleaned, " ${1}${2}")
+
+	if cleaned == head {
+		return line
+	}
+	newLine := cleaned + " " + rest
+	if newLine == line {
+		// Only trailing spaces were normalized away
+		return line
+	}
+	*changes = append(*changes, rewriteChange{Line: lineNum, Rule: r.Name, Before: head, After: cleaned + " "})
+	return newLine
+}
+
+// saveRewriteChanges writes the change log as line,rule,before,after rows.
+func saveRewriteChanges(path string, changes []rewriteChange) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"line", "rule", "before", "after"})
+	for _, change := range changes {
+		w.Write([]string{strconv.Itoa(change.Line), change.Rule, change.Before, change.After})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
@@ -0,0 +1,161 @@
+package code
+
+import (
+	"learn-circassian-helper/modals"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The expected values below are what the removed python_scripts/process_data.py
+// produced for the same lines. Cases marked "differs" document where the Go port
+// deliberately or unavoidably behaves differently:
+//   - Go's \d and \s are ASCII-only, Python's match any Unicode digit or space. The
+//     manifest adds \p{Z} to preceded_by to keep no-break spaces, but non-ASCII
+//     digits (e.g. Arabic-Indic ١) are no longer treated as numbers.
+//   - Python read the files in text mode and wrote \n line endings; the port keeps
+//     \r\n input as it is.
+//   - Python left lines whose headword only lost surrounding spaces out of its
+//     change log; the port logs every line it rewrites.
+
+// manifestRewriteRules returns the compiled preprocess rules of a dictionary in
+// the repository's manifest.
+func manifestRewriteRules(t *testing.T, id int) []rewriteRule {
+	t.Helper()
+	rules, err := compileRewriteRules(manifestDescriptor(t, id).Preprocess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func applyRewriteRules(rules []rewriteRule, line string, changes *[]rewriteChange) string {
+	for _, rule := range rules {
+		line = rule.apply(line, 1, changes)
+	}
+	return line
+}
+
+func TestRewriteRulesThreeVolumes(t *testing.T) {
+	rules := manifestRewriteRules(t, 30)
+	tests := []struct {
+		name, line, want string
+	}{
+		{"dot suffix", "АБА1. текст", "АБА 1. текст"},
+		{"comma suffix", "абэ2, къ", "абэ 2, къ"},
+		{"number before slash", "псы1/псыр", "псы /псыр"},
+		{"spaces before slash", "а1 /б", "а /б"},
+		{"slash at end of line", "гъэ3 /", "гъэ /"},
+		{"palochka digit is not a suffix", "к1э1. к1эм", "к1э 1. к1эм"},
+		{"several digits", "а12. текст", "а 12. текст"},
+		{"dot then comma", "а1.2,", "а 1. 2,"},
+		{"start of line", "1. начало", "1. начало"},
+		{"preceded by space", "слово 1. текст", "слово 1. текст"},
+		{"preceded by digit", "а 12. текст", "а 12. текст"},
+		{"preceded by paren", "(1. текст", "(1. текст"},
+		{"preceded by no-break space", "а\u00a01. текст", "а\u00a01. текст"},
+		// differs: Python's \d matched ١ and gave "а ١. текст"
+		{"arabic-indic digit", "а١. текст", "а١. текст"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes []rewriteChange
+			if got := applyRewriteRules(rules, tt.line, &changes); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if (len(changes) > 0) != (tt.line != tt.want) {
+				t.Errorf("logged %d changes for %q → %q", len(changes), tt.line, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteRulesAdyRus1960(t *testing.T) {
+	rules := manifestRewriteRules(t, 33)
+	tests := []struct {
+		name, line, want string
+		logged           bool
+	}{
+		{"spaced headword with number", "А Б А 1. сущ.", "АБА 1. сущ.", true},
+		{"spaced headword before paren", "И Т У М (рус)", "ИТУМ (рус)", true},
+		{"spaced headword before bracket", "А Б А [x]", "АБА [x]", true},
+		{"number without space", "АБА1, сущ.", "АБА 1, сущ.", true},
+		{"leading spaces", "  А Б А сущ.", "АБА сущ.", true},
+		{"clean headword", "АБА сущ.", "АБА сущ.", false},
+		{"no headword", "сущ. начало", "сущ. начало", false},
+		{"no definition", "НЕТ ОПРЕДЕЛЕНИЯ", "НЕТ ОПРЕДЕЛЕНИЯ", false},
+		// differs: Python wrote the same line but did not log it
+		{"double space after headword", "АБА  сущ.", "АБА сущ.", true},
+		// differs: Python's \d matched ١ and gave "АБА ١ сущ."
+		{"arabic-indic number", "А Б А١ сущ.", "АБА١ сущ.", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes []rewriteChange
+			if got := applyRewriteRules(rules, tt.line, &changes); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if logged := len(changes) > 0; logged != tt.logged {
+				t.Errorf("logged = %v, want %v", logged, tt.logged)
+			}
+		})
+	}
+}
+
+func TestPreprocessDictionaryChangeLog(t *testing.T) {
+	dir := t.TempDir()
+	config := PipelineConfig{InputDir: dir, OutputDir: dir}
+	for _, sub := range []string{config.BackupDir(), config.RawDir(), config.ReportsDir()} {
+		if err := os.MkdirAll(sub, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	desc := manifestDescriptor(t, 33)
+	backup := "А Б А 1. сущ.\r\nАБА сущ.\nИ Т У М (рус)"
+	if err := os.WriteFile(filepath.Join(config.BackupDir(), desc.FileName), []byte(backup), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := preprocessDictionary(config, desc); err != nil {
+		t.Fatal(err)
+	}
+
+	// differs: Python would have written "\n" instead of "\r\n"
+	wantRaw := "АБА 1. сущ.\r\nАБА сущ.\nИТУМ (рус)"
+	if got := readTestFile(t, filepath.Join(config.RawDir(), desc.FileName)); got != wantRaw {
+		t.Errorf("raw file = %q, want %q", got, wantRaw)
+	}
+
+	wantLog := "line,rule,before,after\n" +
+		"1,Headword Spacing,А Б А 1. ,АБА 1. \n" +
+		"3,Headword Spacing,И Т У М ,ИТУМ \n"
+	logPath := filepath.Join(config.ReportsDir(), "33-Ady-Rus-1960.preprocess-changes.csv")
+	if got := readTestFile(t, logPath); got != wantLog {
+		t.Errorf("change log = %q, want %q", got, wantLog)
+	}
+}
+
+// manifestDescriptor returns a dictionary of the repository's manifest.
+func manifestDescriptor(t *testing.T, id int) modals.DictionaryDescriptor {
+	t.Helper()
+	manifest, err := LoadManifest(filepath.Join("..", "content", ManifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, desc := range manifest.Dictionaries {
+		if desc.Id == id {
+			return desc
+		}
+	}
+	t.Fatalf("dictionary %d is not in the manifest", id)
+	return modals.DictionaryDescriptor{}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -1,81 +1,134 @@
 package code
 
 import (
+	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"learn-circassian-helper/modals"
 	"learn-circassian-helper/utils"
+	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+func init() {
+	RegisterConverter(standardHTMLConverter{})
+	RegisterConverter(arabicHTMLConverter{})
+	RegisterConverter(multiKeyHTMLConverter{})
+	RegisterConverter(simpleJSONConverter{})
+	RegisterConverter(richJSONConverter{})
+	RegisterConverter(threeVolumesConverter{})
+	RegisterConverter(turkishAdygheConverter{})
+	RegisterConverter(singleLineRusKbdConverter{})
+	RegisterConverter(adyRus1960Converter{})
+	RegisterConverter(singleLineKbdRuConverter{})
+	RegisterConverter(xdxfConverter{})
+}
+
 // CallConvertPhase01ToPhase02 orchestrates the conversion of raw dictionary data (Phase 1)
-// into standardized JSON formats (Phase 2).
-func CallConvertPhase01ToPhase02() {
-	// Standard HTML Dictionaries
-	ConvertStandardHTML("00-Ady-Ady_AIG.json", modals.NewDictObjectPlainText("Адыгабзэм изэхэф гущы1алъ (2006)", 0, "Ady", "Ru", modals.DictFormatHTML))
-	ConvertStandardHTML("01-Ady-Ady_AP.json", modals.NewDictObjectPlainText("Адыгэ-урыс псалъалъэ (2012)", 1, "Kbd", "Ru", modals.DictFormatHTML))
-
-	// Arabic HTML (Specific cleanup)
-	ConvertArabicHTML("02-Ady-Ara.json", modals.NewDictObjectPlainText("Adel Abdulsalam Lash", 2, "Ady", "Ar", modals.DictFormatPlain))
-
-	// Simple JSON Dictionaries
-	ConvertSimpleJSON("03-Ady-En.json", modals.NewDictObjectJsonObj("Адыгэбзэ-инджылыбзэ гущы1алъэ", 3, "Ady", "En", modals.DictFormatJSON))
-
-	// Rich JSON Dictionaries (With Examples/Cognates)
-	ConvertRichJSON("04-Ady-En_Adam.json", modals.NewDictObjectJsonObj("Adam Shagash's Adyghe to English Dictionary (2020)", 4, "Ady", "En", modals.DictFormatJSON))
-
-	// More Standard HTML
-	ConvertStandardHTML("05-Ady-Rus_Qarden.json", modals.NewDictObjectPlainText("Къардэн (1957)", 5, "Kbd", "Ru", modals.DictFormatHTML))
-	ConvertStandardHTML("06-Ady-Rus_Sherdjes.json", modals.NewDictObjectPlainText("Шэрджэс Алий - Яхуэмыфащэу лъэныкъуэ едгъэза псалъэхэр (2009)", 6, "Kbd", "Ru", modals.DictFormatHTML))
-	ConvertStandardHTML("07-Ady-Rus_Tharkaho.json", modals.NewDictObjectPlainText("Тхьаркъуахъо (1991)", 7, "Ady", "Ru", modals.DictFormatHTML))
-
-	// Multi-Key HTML (Huvaj)
-	ConvertMultiKeyHTML("08-Ady-Tur_Huvaj.json", modals.NewDictObjectPlainText("Хъуажь - Circassian to Turkish (2007)", 8, "Ady/Kbd", "Tr", modals.DictFormatHTML))
-
-	// Simple JSONs
-	ConvertSimpleJSON("09.En-Ady.json", modals.NewDictObjectJsonObj("Simple English to Adyghe dictionary", 9, "En", "Ady", modals.DictFormatJSON))
-	ConvertSimpleJSON("10-En-Ady_Adam.json", modals.NewDictObjectJsonObj("Adam Shagash's English to Adyghe Dictionary (2020)", 10, "En", "Ady", modals.DictFormatJSON))
-	ConvertSimpleJSON("11-En-Kbd-Jonty.json", modals.NewDictObjectJsonObj("Jonty Yamisha's English to Kabardian dictionary", 11, "En", "Kbd", modals.DictFormatJSON))
-	ConvertSimpleJSON("12-En-Kbd-Ziwar.json", modals.NewDictObjectJsonObj("Ziwar Gish's English to Kabardian dictionary", 12, "En", "Kbd", modals.DictFormatJSON))
-	ConvertSimpleJSON("13-Kbd-Ar-Jonty.json", modals.NewDictObjectJsonObj("Jonty Yamisha's Kabardian to Arabic dictionary", 13, "Ar", "Kbd", modals.DictFormatJSON))
-
-	// Rich JSON
-	ConvertRichJSON("14-Kbd-En-2-Jonty.json", modals.NewDictObjectJsonObj("Jonty Yamisha's Kabardian to English dictionary 2", 14, "Kbd", "En", modals.DictFormatJSON))
-
-	// Simple JSONs
-	ConvertSimpleJSON("15-Kbd-En-Jonty.json", modals.NewDictObjectJsonObj("Jonty Yamisha's Kabardian to English dictionary", 15, "Kbd", "En", modals.DictFormatJSON))
-	ConvertSimpleJSON("16-Kbd-En-Ziwar.json", modals.NewDictObjectJsonObj("Ziwar Gish's Kabardian to English dictionary", 16, "Kbd", "En", modals.DictFormatJSON))
-	ConvertSimpleJSON("17-Kbd-En_Amjad.json", modals.NewDictObjectJsonObj("Amjad Jaimoukha's Kabardian to English dictionary", 17, "Kbd", "En", modals.DictFormatJSON))
-	ConvertSimpleJSON("18-Kbd-Ru&En.json", modals.NewDictObjectJsonObj("Kabardian to Russian & English", 18, "Kbd", "En", modals.DictFormatJSON))
-
-	// Rich JSON
-	ConvertRichJSON("19-Kbd-Ru-2-Jonty.json", modals.NewDictObjectJsonObj("Jonty Yamisha's Kabardian to Russian dictionary 2", 19, "Kbd", "Ru", modals.DictFormatJSON))
-
-	// Simple JSONs
-	ConvertSimpleJSON("20-Kbd-Ru-Jonty.json", modals.NewDictObjectJsonObj("Jonty Yamisha's Kabardian to Russian dictionary", 20, "Kbd", "Ru", modals.DictFormatJSON))
-	ConvertSimpleJSON("21-Kbd-Tu-Jonty.json", modals.NewDictObjectJsonObj("Jonty Yamisha's Kabardian to Turkish dictionary", 21, "Kbd", "Tr", modals.DictFormatJSON))
-	ConvertSimpleJSON("22-Ru-Kbd-Jonty.json", modals.NewDictObjectJsonObj("Jonty Yamisha's Russian to Kabardian dictionary", 22, "Ru", "Kbd", modals.DictFormatJSON))
-
-	// Standard HTML
-	ConvertStandardHTML("23-Rus-Ady_Blaghoj.json", modals.NewDictObjectPlainText("Блэгъожъ (1991)", 23, "Ru", "Ady", modals.DictFormatHTML))
-	ConvertStandardHTML("24-Rus-Ady_UAG.json", modals.NewDictObjectPlainText("Одэжьдэкъо (1960)", 24, "Ru", "Ady", modals.DictFormatHTML))
-	ConvertStandardHTML("25-Rus-Ady_UASP.json", modals.NewDictObjectPlainText("Урыс-адыгэ школ псалъалъэ (1991)", 25, "Ru", "Kbd", modals.DictFormatHTML))
-
-	// Simple JSON
-	ConvertSimpleJSON("26-Tu-Kbd-Jonty.json", modals.NewDictObjectJsonObj("Jonty Yamisha's Turkish to Kabardian dictionary", 26, "Tr", "Kbd", modals.DictFormatJSON))
-
-	// Standard HTML
-	ConvertStandardHTML("27-Tur-Ady_Abaze.json", modals.NewDictObjectPlainText("Ибрагим Алхаз Абазэ (2005)", 27, "Tr", "Kbd", modals.DictFormatHTML))
-	ConvertStandardHTML("28-Tur-Ady_Huvaj.json", modals.NewDictObjectPlainText("Хъуажь - Turkish to Circassian (2007)", 28, "Tr", "Ady/Kbd", modals.DictFormatHTML))
-	ConvertStandardHTML("29-Tur-Ady_Teshu.json", modals.NewDictObjectPlainText("Т1эшъу (1991)", 29, "Tr", "Ady", modals.DictFormatHTML))
-
-	// Plain Text Dictionaries
-	ConvertThreeVolumes("30-Ady-Rus_ThreeVolumes.txt", modals.NewDictObjectPlainText("Адыгабзэм изэхэф гущы1алъ томищ мэхъу (2011)", 30, "Ady", "Ru", modals.DictFormatPlain))
-	ConvertTurkishAdyghe("31-Tu-Ady_Hilmi.txt", modals.NewDictObjectPlainText("Ацумыжъ Хилми (2013)", 31, "Tr", "Ady", modals.DictFormatPlain))
-	ConvertSingleLineRusKbd("32-Rus-Kbd_Nalchik_2013.txt", modals.NewDictObjectPlainText("Еджап1эм папщ1э урыс-адыгэ псалъалъэ (2013)", 32, "Ru", "Kbd", modals.DictFormatPlain))
-	ConvertAdyRus1960("33-Ady-Rus-1960.txt", modals.NewDictObjectPlainText("Адыгабзэм изэхэф гущы1алъ жъы (1960)", 33, "Ady", "Ru", modals.DictFormatPlain))
-	ConvertSingleLineKbdRu("34-Kbd-Ru-2008.txt", modals.NewDictObjectPlainText("адыгэ-урыс псалъалъэ (2008)", 34, "Kbd", "Ru", modals.DictFormatPlain))
+// into standardized JSON formats (Phase 2). Every selected dictionary in the manifest
+// is parsed by the registered converter it names, up to Config.Jobs at a time. A
+// dictionary that fails is recorded in the summary and the remaining ones are still converted.
+func CallConvertPhase01ToPhase02(run *PipelineRun) error {
+	dictionaries, err := run.Config.SelectDictionaries(run.Manifest)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(run.Config.JSONDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Saving the cache as we go keeps finished dictionaries cached even if a later one aborts the run
+	defer saveBuildCache(run.Cache)
+
+	return forEachDictionary(run.Config.Jobs, dictionaries, func(_ int, desc modals.DictionaryDescriptor) error {
+		cached, err := convertPhase01Dictionary(run, desc)
+		if err != nil {
+			return run.Summary.Fail(desc, "convert", err)
+		}
+		if cached {
+			run.Summary.Cached(desc, "convert")
+		} else {
+			run.Summary.Succeeded(desc, "convert")
+		}
+		return nil
+	})
+}
+
+// convertPhase01Dictionary converts a single raw dictionary and saves its Phase 02 file.
+// It reports true when the existing Phase 02 file was up to date and the work was skipped.
+func convertPhase01Dictionary(run *PipelineRun, desc modals.DictionaryDescriptor) (bool, error) {
+	srcFile := filepath.Join(run.Config.RawDir(), desc.FileName)
+	distFile := filepath.Join(run.Config.JSONDir(), Phase02FileName(desc))
+
+	// The manifest has already been validated, so the converter always exists
+	converter, _ := LookupConverter(desc.Converter)
+
+	rawHash, err := utils.HashFile(srcFile)
+	if err != nil {
+		return false, err
+	}
+	// A cached dictionary still needs its parse reports, which are rewritten if missing
+	if run.Config.useBuildCache() && run.Cache.ConvertUpToDate(desc, rawHash, converter, distFile) &&
+		parseReportExists(run.Config.ReportsDir(), desc.FileName) {
+		fmt.Printf("Up to date, skipping conversion: %s\n", srcFile)
+		return true, nil
+	}
+
+	fmt.Printf("Starting conversion (%s): %s\n", converter.Name(), srcFile)
+
+	dictObj, report, err := convertFile(converter, srcFile, desc)
+	if err != nil {
+		return false, fmt.Errorf("failed to convert %s: %w", srcFile, err)
+	}
+
+	if err := writeParseReport(run.Config.ReportsDir(), report); err != nil {
+		return false, err
+	}
+
+	if err := savePhaseFile(run.Config, distFile, dictObj); err != nil {
+		return false, fmt.Errorf("failed to save %s: %w", distFile, err)
+	}
+	return false, run.Cache.RecordConvert(desc, rawHash, converter, distFile)
+}
+
+// convertFile opens a raw dictionary file and runs it through the converter.
+func convertFile(converter Converter, srcFile string, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error) {
+	f, err := os.Open(srcFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	return converter.Convert(f, desc)
+}
+
+// Phase02FileName returns the Phase 02 JSON file name for a dictionary.
+// Sources in other formats (.txt, .xdxf) are renamed to .json.
+func Phase02FileName(desc modals.DictionaryDescriptor) string {
+	if ext := filepath.Ext(desc.FileName); ext != ".json" {
+		return strings.TrimSuffix(desc.FileName, ext) + ".json"
+	}
+	return desc.FileName
+}
+
+// newDictObjectPlainText creates an empty plain-text dictionary object for a descriptor.
+func newDictObjectPlainText(desc modals.DictionaryDescriptor) *modals.DictObjectPlainText {
+	format, _ := modals.ParseDictFormat(desc.Format)
+	return modals.NewDictObjectPlainText(desc.Title, desc.Id, desc.FromLang, desc.ToLang, format)
+}
+
+// newDictObjectJsonObj creates an empty structured dictionary object for a descriptor.
+func newDictObjectJsonObj(desc modals.DictionaryDescriptor) *modals.DictObjectJsonObj {
+	format, _ := modals.ParseDictFormat(desc.Format)
+	return modals.NewDictObjectJsonObj(desc.Title, desc.Id, desc.FromLang, desc.ToLang, format)
 }
 
 // formatNumberDots replaces " N." with "\n\tN." for numbered sub-definitions.
@@ -107,15 +160,18 @@ func formatNumberDotsStartAware(s string) string {
 	return s
 }
 
-// ConvertMultiKeyHTML handles dictionaries where a single key might contain multiple
+// multiKeyHTMLConverter handles dictionaries where a single key might contain multiple
 // variants split by slashes (e.g., "WordA / WordB Suffix"). It expands these
 // into separate entries pointing to the same definition.
-func ConvertMultiKeyHTML(fileName string, dictObj *modals.DictObjectPlainText) {
-	srcFile := fmt.Sprintf("content/phase-01-raw-data/%s", fileName)
-	distFile := fmt.Sprintf("content/phase-02-json-data/%s", fileName)
-	invalidLinesList := make([]string, 0)
+type multiKeyHTMLConverter struct{}
+
+func (multiKeyHTMLConverter) Name() string { return "multi-key-html" }
 
-	fmt.Printf("Starting conversion (Multi-Key HTML): %s\n", srcFile)
+func (multiKeyHTMLConverter) Version() int { return 2 }
+
+func (multiKeyHTMLConverter) Convert(r io.Reader, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error) {
+	dictObj := newDictObjectPlainText(desc)
+	report := modals.NewParseReport(desc.FileName)
 
 	// Nested helper to expand keys
 	expandKey := func(key string) []string {
@@ -157,32 +213,20 @@ func ConvertMultiKeyHTML(fileName string, dictObj *modals.DictObjectPlainText) {
 		return parts
 	}
 
-	err := utils.ReadFileLineByLine(srcFile, func(line string, index int) error {
-		line = strings.TrimSpace(line)
-		if line == "" || line == "{" || line == "}" {
-			return nil
-		}
-
-		split := strings.SplitN(line, ":", 2)
-		if len(split) < 2 {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("Invalid line %d: %s", index, line))
+	err := utils.ReadJSONObject(r, func(rawKey string, rawValue json.RawMessage, line int) error {
+		if rawKey == "" {
 			return nil
 		}
 
-		rawKey := strings.Trim(strings.TrimSpace(split[0]), "\"")
-		if rawKey == "" {
+		var value string
+		if err := json.Unmarshal(rawValue, &value); err != nil {
+			report.AddIssue(line, modals.ParseIssueJSONParseError, jsonEntryText(rawKey, rawValue), err.Error())
 			return nil
 		}
-
-		// Use the nested expansion function
-		expandedKeys := expandKey(rawKey)
-
-		value := strings.TrimSuffix(strings.TrimSpace(split[1]), ",")
-		value = strings.Trim(value, "\"")
-		value = strings.ReplaceAll(value, "\\\"", "\"")
 		value = utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(value)
 
-		for _, expandedKey := range expandedKeys {
+		// Use the nested expansion function
+		for _, expandedKey := range expandKey(rawKey) {
 			key := strings.ToLower(expandedKey)
 			key = utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(key)
 
@@ -192,60 +236,43 @@ func ConvertMultiKeyHTML(fileName string, dictObj *modals.DictObjectPlainText) {
 			dictObj.WordsToPlainTextMap[key] = append(dictObj.WordsToPlainTextMap[key], value)
 		}
 
-		if index%1000 == 0 {
-			fmt.Printf("Processed line %d...\n", index)
+		if line%1000 == 0 {
+			fmt.Printf("Processed line %d...\n", line)
 		}
 		return nil
 	})
 
 	if err != nil {
-		panic(err)
+		return nil, report, err
 	}
 
-	if len(invalidLinesList) > 0 {
-		fmt.Printf("\n--Invalid lines in %s:--\n", srcFile)
-		for idx, line := range invalidLinesList {
-			fmt.Printf("%d. %s\n", idx, line)
-		}
-	}
-
-	err = utils.SaveDictToJSON(distFile, dictObj)
-	if err != nil {
-		panic(err)
-	}
+	return dictObj, report, nil
 }
 
-// ConvertStandardHTML processes standard dictionaries where the value is an HTML string.
+// standardHTMLConverter processes standard dictionaries where the value is an HTML string.
 // It performs standard Circassian letter cleaning on both Keys and Values.
-func ConvertStandardHTML(fileName string, dictObj *modals.DictObjectPlainText) {
-	srcFile := fmt.Sprintf("content/phase-01-raw-data/%s", fileName)
-	distFile := fmt.Sprintf("content/phase-02-json-data/%s", fileName)
-	invalidLinesList := make([]string, 0)
+type standardHTMLConverter struct{}
 
-	fmt.Printf("Starting conversion (Standard HTML): %s\n", srcFile)
+func (standardHTMLConverter) Name() string { return "standard-html" }
 
-	err := utils.ReadFileLineByLine(srcFile, func(line string, index int) error {
-		line = strings.TrimSpace(line)
-		if line == "" || line == "{" || line == "}" {
-			return nil
-		}
+func (standardHTMLConverter) Version() int { return 2 }
 
-		split := strings.SplitN(line, ":", 2)
-		if len(split) < 2 {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("Invalid line %d: %s", index, line))
-			return nil
-		}
+func (standardHTMLConverter) Convert(r io.Reader, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error) {
+	dictObj := newDictObjectPlainText(desc)
+	report := modals.NewParseReport(desc.FileName)
 
-		key := strings.Trim(strings.TrimSpace(split[0]), "\"")
-		if key == "" {
+	err := utils.ReadJSONObject(r, func(rawKey string, rawValue json.RawMessage, line int) error {
+		if rawKey == "" {
 			return nil
 		}
-		key = strings.ToLower(key)
+		key := strings.ToLower(rawKey)
 		key = utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(key)
 
-		value := strings.TrimSuffix(strings.TrimSpace(split[1]), ",")
-		value = strings.Trim(value, "\"")
-		value = strings.ReplaceAll(value, "\\\"", "\"")
+		var value string
+		if err := json.Unmarshal(rawValue, &value); err != nil {
+			report.AddIssue(line, modals.ParseIssueJSONParseError, jsonEntryText(rawKey, rawValue), err.Error())
+			return nil
+		}
 		value = utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(value)
 
 		if _, exists := dictObj.WordsToPlainTextMap[key]; !exists {
@@ -253,64 +280,48 @@ func ConvertStandardHTML(fileName string, dictObj *modals.DictObjectPlainText) {
 		}
 		dictObj.WordsToPlainTextMap[key] = append(dictObj.WordsToPlainTextMap[key], value)
 
-		if index%1000 == 0 {
-			fmt.Printf("Processed line %d...\n", index)
+		if line%1000 == 0 {
+			fmt.Printf("Processed line %d...\n", line)
 		}
 		return nil
 	})
 
 	if err != nil {
-		panic(err)
-	}
-
-	if len(invalidLinesList) > 0 {
-		fmt.Printf("\n--Invalid lines in %s:--\n", srcFile)
-		for idx, line := range invalidLinesList {
-			fmt.Printf("%d. %s\n", idx, line)
-		}
+		return nil, report, err
 	}
 
-	err = utils.SaveDictToJSON(distFile, dictObj)
-	if err != nil {
-		panic(err)
-	}
+	return dictObj, report, nil
 }
 
-// ConvertArabicHTML processes dictionaries with Arabic content.
+// arabicHTMLConverter processes dictionaries with Arabic content.
 // It includes specific logic to strip HTML tags and collapse whitespace for cleaner plain text.
-func ConvertArabicHTML(fileName string, dictObj *modals.DictObjectPlainText) {
-	srcFile := fmt.Sprintf("content/phase-01-raw-data/%s", fileName)
-	distFile := fmt.Sprintf("content/phase-02-json-data/%s", fileName)
-	invalidLinesList := make([]string, 0)
+type arabicHTMLConverter struct{}
 
-	fmt.Printf("Starting conversion (Arabic Plain): %s\n", srcFile)
+func (arabicHTMLConverter) Name() string { return "arabic-html" }
 
-	err := utils.ReadFileLineByLine(srcFile, func(line string, index int) error {
-		line = strings.TrimSpace(line)
-		if line == "" || line == "{" || line == "}" {
-			return nil
-		}
+func (arabicHTMLConverter) Version() int { return 2 }
 
-		split := strings.SplitN(line, ":", 2)
-		if len(split) < 2 {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("Invalid line %d: %s", index, line))
-			return nil
-		}
+func (arabicHTMLConverter) Convert(r io.Reader, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error) {
+	dictObj := newDictObjectPlainText(desc)
+	report := modals.NewParseReport(desc.FileName)
 
-		key := strings.Trim(strings.TrimSpace(split[0]), "\"")
-		if key == "" {
+	err := utils.ReadJSONObject(r, func(rawKey string, rawValue json.RawMessage, line int) error {
+		if rawKey == "" {
 			return nil
 		}
-		key = strings.ToLower(key)
+		key := strings.ToLower(rawKey)
 		key = utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(key)
 
-		value := strings.TrimSuffix(strings.TrimSpace(split[1]), ",")
-		value = strings.Trim(value, "\"")
+		var value string
+		if err := json.Unmarshal(rawValue, &value); err != nil {
+			report.AddIssue(line, modals.ParseIssueJSONParseError, jsonEntryText(rawKey, rawValue), err.Error())
+			return nil
+		}
 
 		// HTML Stripping & Whitespace Cleaning
-		value = strings.ReplaceAll(value, "<div style=\\\"margin-left:1em\\\">", " ")
+		value = strings.ReplaceAll(value, "<div style=\"margin-left:1em\">", " ")
 		value = strings.ReplaceAll(value, "</div>", " ")
-		value = strings.ReplaceAll(value, "\\\"", "'")
+		value = strings.ReplaceAll(value, "\"", "'")
 		value = strings.Join(strings.Fields(value), " ")
 
 		if value == "" {
@@ -334,37 +345,30 @@ func ConvertArabicHTML(fileName string, dictObj *modals.DictObjectPlainText) {
 			dictObj.WordsToPlainTextMap[key] = append(dictObj.WordsToPlainTextMap[key], value)
 		}
 
-		if index%1000 == 0 {
-			fmt.Printf("Processed line %d...\n", index)
+		if line%1000 == 0 {
+			fmt.Printf("Processed line %d...\n", line)
 		}
 		return nil
 	})
 
 	if err != nil {
-		panic(err)
+		return nil, report, err
 	}
 
-	if len(invalidLinesList) > 0 {
-		fmt.Printf("\n--Invalid lines in %s:--\n", srcFile)
-		for idx, line := range invalidLinesList {
-			fmt.Printf("%d. %s\n", idx, line)
-		}
-	}
-
-	err = utils.SaveDictToJSON(distFile, dictObj)
-	if err != nil {
-		panic(err)
-	}
+	return dictObj, report, nil
 }
 
-// ConvertSimpleJSON processes JSONs with basic structure (Type, Definitions, Links).
+// simpleJSONConverter processes JSONs with basic structure (Type, Definitions, Links).
 // It handles cleaning and formatting of "Clarity" fields in links.
-func ConvertSimpleJSON(fileName string, dictObj *modals.DictObjectJsonObj) {
-	srcFile := fmt.Sprintf("content/phase-01-raw-data/%s", fileName)
-	distFile := fmt.Sprintf("content/phase-02-json-data/%s", fileName)
-	invalidLinesList := make([]string, 0)
+type simpleJSONConverter struct{}
+
+func (simpleJSONConverter) Name() string { return "simple-json" }
 
-	fmt.Printf("Starting conversion (Simple JSON): %s\n", srcFile)
+func (simpleJSONConverter) Version() int { return 2 }
+
+func (simpleJSONConverter) Convert(r io.Reader, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error) {
+	dictObj := newDictObjectJsonObj(desc)
+	report := modals.NewParseReport(desc.FileName)
 
 	type RawLink struct {
 		Word    string `json:"word"`
@@ -382,7 +386,7 @@ func ConvertSimpleJSON(fileName string, dictObj *modals.DictObjectJsonObj) {
 	cleanText := func(text string) string {
 		text = strings.TrimSpace(text)
 		isCircassianTarget := strings.ToLower(dictObj.ToLang) == "ady" || strings.ToLower(dictObj.ToLang) == "kbd"
-		isSpecialMixedFile := strings.Contains(fileName, "18-Kbd-Ru&En.json")
+		isSpecialMixedFile := strings.Contains(desc.FileName, "18-Kbd-Ru&En.json")
 
 		if isCircassianTarget || isSpecialMixedFile {
 			text = utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(text)
@@ -390,42 +394,21 @@ func ConvertSimpleJSON(fileName string, dictObj *modals.DictObjectJsonObj) {
 		return text
 	}
 
-	err := utils.ReadFileLineByLine(srcFile, func(line string, index int) error {
-		line = strings.TrimSpace(line)
-		if line == "" || line == "{" || line == "}" {
-			return nil
-		}
-		line = strings.TrimSuffix(line, ",")
-
-		split := strings.SplitN(line, ":", 2)
-		if len(split) < 2 {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("Invalid line %d: %s", index, line))
-			return nil
-		}
-
-		rawKey := strings.TrimSpace(split[0])
-		rawKey = strings.Trim(rawKey, "\"")
+	err := utils.ReadJSONObject(r, func(rawKey string, rawValue json.RawMessage, line int) error {
+		key := rawKey
 		if strings.ToLower(dictObj.FromLang) == "ady" || strings.ToLower(dictObj.FromLang) == "kbd" {
-			rawKey = utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(rawKey)
+			key = utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(key)
 		}
-		key := strings.ToLower(rawKey)
-
-		rawValueStr := strings.TrimSpace(split[1])
-
-		// Sanitize JSON string boundaries
-		firstBrace := strings.Index(rawValueStr, "{")
-		lastBrace := strings.LastIndex(rawValueStr, "}")
+		key = strings.ToLower(key)
 
-		if firstBrace != -1 && lastBrace != -1 && lastBrace > firstBrace {
-			rawValueStr = rawValueStr[firstBrace : lastBrace+1]
-		} else {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("No JSON object found in line %d: %s", index, line))
+		if !isJSONObject(rawValue) {
+			report.AddIssue(line, modals.ParseIssueNoJSONObject, jsonEntryText(rawKey, rawValue), "")
 			return nil
 		}
 
 		var rawEntry RawEntry
-		if err := json.Unmarshal([]byte(rawValueStr), &rawEntry); err != nil {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("JSON Parse Error line %d: %v", index, err))
+		if err := json.Unmarshal(rawValue, &rawEntry); err != nil {
+			report.AddIssue(line, modals.ParseIssueJSONParseError, jsonEntryText(rawKey, rawValue), err.Error())
 			return nil
 		}
 
@@ -462,37 +445,30 @@ func ConvertSimpleJSON(fileName string, dictObj *modals.DictObjectJsonObj) {
 			}
 		}
 
-		if index%1000 == 0 {
-			fmt.Printf("Processed line %d...\n", index)
+		if line%1000 == 0 {
+			fmt.Printf("Processed line %d...\n", line)
 		}
 		return nil
 	})
 
 	if err != nil {
-		panic(err)
-	}
-
-	if len(invalidLinesList) > 0 {
-		fmt.Printf("\n--Invalid lines in %s:--\n", srcFile)
-		for idx, line := range invalidLinesList {
-			fmt.Printf("%d. %s\n", idx, line)
-		}
+		return nil, report, err
 	}
 
-	err = utils.SaveDictToJSON(distFile, dictObj)
-	if err != nil {
-		panic(err)
-	}
+	return dictObj, report, nil
 }
 
-// ConvertRichJSON processes complex JSON structures containing definitions, examples,
+// richJSONConverter processes complex JSON structures containing definitions, examples,
 // synonyms, and cognates. It handles cleaning of pipe characters '|' in examples.
-func ConvertRichJSON(fileName string, dictObj *modals.DictObjectJsonObj) {
-	srcFile := fmt.Sprintf("content/phase-01-raw-data/%s", fileName)
-	distFile := fmt.Sprintf("content/phase-02-json-data/%s", fileName)
-	invalidLinesList := make([]string, 0)
+type richJSONConverter struct{}
 
-	fmt.Printf("Starting conversion (Rich JSON): %s\n", srcFile)
+func (richJSONConverter) Name() string { return "rich-json" }
+
+func (richJSONConverter) Version() int { return 2 }
+
+func (richJSONConverter) Convert(r io.Reader, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error) {
+	dictObj := newDictObjectJsonObj(desc)
+	report := modals.NewParseReport(desc.FileName)
 
 	type RawExample struct {
 		Sentence    string `json:"sentence"`
@@ -524,32 +500,21 @@ func ConvertRichJSON(fileName string, dictObj *modals.DictObjectJsonObj) {
 		return text
 	}
 
-	err := utils.ReadFileLineByLine(srcFile, func(line string, index int) error {
-		line = strings.TrimSpace(line)
-		if line == "" || line == "{" || line == "}" {
-			return nil
+	err := utils.ReadJSONObject(r, func(rawKey string, rawValue json.RawMessage, line int) error {
+		key := rawKey
+		if strings.ToLower(dictObj.FromLang) == "ady" || strings.ToLower(dictObj.FromLang) == "kbd" {
+			key = utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(key)
 		}
-		line = strings.TrimSuffix(line, ",")
+		key = strings.ToLower(key)
 
-		split := strings.SplitN(line, ":", 2)
-		if len(split) < 2 {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("Invalid line %d: %s", index, line))
+		if !isJSONObject(rawValue) {
+			report.AddIssue(line, modals.ParseIssueNoJSONObject, jsonEntryText(rawKey, rawValue), "")
 			return nil
 		}
 
-		rawKey := strings.TrimSpace(split[0])
-		rawKey = strings.Trim(rawKey, "\"")
-
-		if strings.ToLower(dictObj.FromLang) == "ady" || strings.ToLower(dictObj.FromLang) == "kbd" {
-			rawKey = utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(rawKey)
-		}
-		key := strings.ToLower(rawKey)
-
-		rawValueStr := strings.TrimSpace(split[1])
 		var rawEntry RawEntry
-
-		if err := json.Unmarshal([]byte(rawValueStr), &rawEntry); err != nil {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("JSON Parse Error line %d: %v", index, err))
+		if err := json.Unmarshal(rawValue, &rawEntry); err != nil {
+			report.AddIssue(line, modals.ParseIssueJSONParseError, jsonEntryText(rawKey, rawValue), err.Error())
 			return nil
 		}
 
@@ -603,38 +568,31 @@ func ConvertRichJSON(fileName string, dictObj *modals.DictObjectJsonObj) {
 			}
 		}
 
-		if index%1000 == 0 {
-			fmt.Printf("Processed line %d...\n", index)
+		if line%1000 == 0 {
+			fmt.Printf("Processed line %d...\n", line)
 		}
 		return nil
 	})
 
 	if err != nil {
-		panic(err)
+		return nil, report, err
 	}
 
-	if len(invalidLinesList) > 0 {
-		fmt.Printf("\n--Invalid lines in %s:--\n", srcFile)
-		for idx, line := range invalidLinesList {
-			fmt.Printf("%d. %s\n", idx, line)
-		}
-	}
-
-	err = utils.SaveDictToJSON(distFile, dictObj)
-	if err != nil {
-		panic(err)
-	}
+	return dictObj, report, nil
 }
 
-// ConvertThreeVolumes processes the three-volume Adyghe explanatory dictionary (plain text).
+// threeVolumesConverter processes the three-volume Adyghe explanatory dictionary (plain text).
 // Each entry starts with a fully-capitalized Cyrillic word. Continuation lines are appended
 // to the current entry. Numbered sub-definitions (1., 2., ...) get newline+tab formatting.
-func ConvertThreeVolumes(fileName string, dictObj *modals.DictObjectPlainText) {
-	srcFile := fmt.Sprintf("content/phase-01-raw-data/%s", fileName)
-	distFile := fmt.Sprintf("content/phase-02-json-data/%s", strings.TrimSuffix(fileName, ".txt")+".json")
-	invalidLinesList := make([]string, 0)
+type threeVolumesConverter struct{}
+
+func (threeVolumesConverter) Name() string { return "three-volumes" }
 
-	fmt.Printf("Starting conversion (Three Volumes): %s\n", srcFile)
+func (threeVolumesConverter) Version() int { return 1 }
+
+func (threeVolumesConverter) Convert(r io.Reader, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error) {
+	dictObj := newDictObjectPlainText(desc)
+	report := modals.NewParseReport(desc.FileName)
 
 	var currentKey string
 	var currentValue strings.Builder
@@ -654,7 +612,7 @@ func ConvertThreeVolumes(fileName string, dictObj *modals.DictObjectPlainText) {
 		dictObj.WordsToPlainTextMap[spelling] = append(dictObj.WordsToPlainTextMap[spelling], value)
 	}
 
-	err := utils.ReadFileLineByLine(srcFile, func(line string, index int) error {
+	err := utils.ReadLineByLine(r, func(line string, index int) error {
 		trimmedLine := strings.TrimSpace(line)
 		trimmedLine = utils.StripZeroWidthChars(trimmedLine)
 
@@ -665,7 +623,7 @@ func ConvertThreeVolumes(fileName string, dictObj *modals.DictObjectPlainText) {
 		words := strings.Fields(normalizedLine)
 
 		if len(words) == 0 {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("Empty line %d", index))
+			report.AddIssue(index+1, modals.ParseIssueEmptyLine, line, "")
 			return nil
 		}
 
@@ -692,34 +650,67 @@ func ConvertThreeVolumes(fileName string, dictObj *modals.DictObjectPlainText) {
 	})
 
 	if err != nil {
-		panic(err)
+		return nil, report, err
 	}
 
 	flushEntry()
 
-	if len(invalidLinesList) > 0 {
-		fmt.Printf("\n--Invalid lines in %s:--\n", srcFile)
-		for idx, line := range invalidLinesList {
-			fmt.Printf("%d. %s\n", idx, line)
-		}
+	return dictObj, report, nil
+}
+
+// Forms reads the inflected forms from the grammar section of a headword line, e.g.
+// "АПТЕК, -кэх / аптекэ, -кэхэр, -кэмэ // -кэхэм. 1. Аптека ...". A full form
+// ("аптекэ") starts a new stem; a suffix ("-кэхэр") replaces the stem from the last
+// occurrence of its first letter, giving "аптекэхэр".
+func (threeVolumesConverter) Forms(key, value string) []string {
+	section, _, found := strings.Cut(value, ".")
+	if !found {
+		return nil
+	}
+	tokens := strings.FieldsFunc(section, func(r rune) bool { return r == ',' || r == '/' })
+	if len(tokens) < 2 {
+		return nil
 	}
 
-	err = utils.SaveDictToJSON(distFile, dictObj)
-	if err != nil {
-		panic(err)
+	var forms []string
+	stem := key
+	for _, token := range tokens[1:] {
+		token = strings.ToLower(strings.TrimSpace(token))
+		if token == "" || strings.ContainsFunc(token, unicode.IsSpace) {
+			return nil // not a list of forms but running text
+		}
+
+		form := token
+		if suffix, isSuffix := strings.CutPrefix(token, "-"); isSuffix {
+			first, _ := utf8.DecodeRuneInString(suffix)
+			i := strings.LastIndex(stem, string(first))
+			if suffix == "" || i < 0 {
+				continue
+			}
+			form = stem[:i] + suffix
+		} else {
+			stem = token
+		}
+		if form != key && !slices.Contains(forms, form) {
+			forms = append(forms, form)
+		}
 	}
+	return forms
 }
 
-// ConvertTurkishAdyghe processes the Turkish-Adyghe dictionary by Hilmi (plain text).
+// turkishAdygheConverter processes the Turkish-Adyghe dictionary by Hilmi (plain text).
 // Keys are fully-capitalized Latin/Turkish words. Values contain Circassian text.
 // Turkish "i" in keys is preserved (no polachka conversion on keys).
 // Polachka conversion is only applied to values (which contain Circassian text).
-func ConvertTurkishAdyghe(fileName string, dictObj *modals.DictObjectPlainText) {
-	srcFile := fmt.Sprintf("content/phase-01-raw-data/%s", fileName)
-	distFile := fmt.Sprintf("content/phase-02-json-data/%s", strings.TrimSuffix(fileName, ".txt")+".json")
-	invalidLinesList := make([]string, 0)
+type turkishAdygheConverter struct{}
+
+func (turkishAdygheConverter) Name() string { return "turkish-adyghe" }
+
+func (turkishAdygheConverter) Version() int { return 1 }
 
-	fmt.Printf("Starting conversion (Turkish-Adyghe): %s\n", srcFile)
+func (turkishAdygheConverter) Convert(r io.Reader, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error) {
+	dictObj := newDictObjectPlainText(desc)
+	report := modals.NewParseReport(desc.FileName)
 
 	var currentKey string
 	var currentValue strings.Builder
@@ -741,7 +732,7 @@ func ConvertTurkishAdyghe(fileName string, dictObj *modals.DictObjectPlainText)
 		dictObj.WordsToPlainTextMap[spelling] = append(dictObj.WordsToPlainTextMap[spelling], value)
 	}
 
-	err := utils.ReadFileLineByLine(srcFile, func(line string, index int) error {
+	err := utils.ReadLineByLine(r, func(line string, index int) error {
 		trimmedLine := strings.TrimSpace(line)
 		trimmedLine = utils.StripZeroWidthChars(trimmedLine)
 		words := strings.Fields(trimmedLine)
@@ -751,10 +742,10 @@ func ConvertTurkishAdyghe(fileName string, dictObj *modals.DictObjectPlainText)
 		if strings.TrimSpace(trimmedLine) == "" {
 			return nil
 		} else if len(words) == 0 {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("Empty line %d", index))
+			report.AddIssue(index+1, modals.ParseIssueEmptyLine, line, "")
 		} else if len(words) == 1 && len(words[0]) == 3 && strings.Contains(words[0], "-") {
 			// Section headers like "A-B" — skip
-			invalidLinesList = append(invalidLinesList, trimmedLine)
+			report.AddIssue(index+1, modals.ParseIssueSectionHeader, trimmedLine, "")
 		} else if len(words) > 0 && utils.IsFullyCapitalized(words[0]) && !utils.StartsWithNumber(words[0]) && !utils.StartsWithSpecialCharacter(words[0]) {
 			flushEntry()
 			currentKey = utils.RemoveSuffixes(words[0])
@@ -774,41 +765,34 @@ func ConvertTurkishAdyghe(fileName string, dictObj *modals.DictObjectPlainText)
 	})
 
 	if err != nil {
-		panic(err)
+		return nil, report, err
 	}
 
 	flushEntry()
 
-	if len(invalidLinesList) > 0 {
-		fmt.Printf("\n--Invalid lines in %s:--\n", srcFile)
-		for idx, line := range invalidLinesList {
-			fmt.Printf("%d. %s\n", idx, line)
-		}
-	}
-
-	err = utils.SaveDictToJSON(distFile, dictObj)
-	if err != nil {
-		panic(err)
-	}
+	return dictObj, report, nil
 }
 
-// ConvertSingleLineRusKbd processes the Russian-Kabardian school dictionary (Nalchik 2013).
+// singleLineRusKbdConverter processes the Russian-Kabardian school dictionary (Nalchik 2013).
 // Each line is a single entry. The first word is the Russian key.
 // Polachka conversion is applied to the value (Kabardian content) but not the Russian key.
-func ConvertSingleLineRusKbd(fileName string, dictObj *modals.DictObjectPlainText) {
-	srcFile := fmt.Sprintf("content/phase-01-raw-data/%s", fileName)
-	distFile := fmt.Sprintf("content/phase-02-json-data/%s", strings.TrimSuffix(fileName, ".txt")+".json")
-	invalidLinesList := make([]string, 0)
+type singleLineRusKbdConverter struct{}
+
+func (singleLineRusKbdConverter) Name() string { return "single-line-rus-kbd" }
 
-	fmt.Printf("Starting conversion (Rus-Kbd Single Line): %s\n", srcFile)
+func (singleLineRusKbdConverter) Version() int { return 1 }
 
-	err := utils.ReadFileLineByLine(srcFile, func(line string, index int) error {
+func (singleLineRusKbdConverter) Convert(r io.Reader, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error) {
+	dictObj := newDictObjectPlainText(desc)
+	report := modals.NewParseReport(desc.FileName)
+
+	err := utils.ReadLineByLine(r, func(line string, index int) error {
 		line = strings.TrimSpace(line)
 		line = utils.StripZeroWidthChars(line)
 
 		words := strings.Fields(line)
 		if len(words) == 0 {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("Empty line %d", index))
+			report.AddIssue(index+1, modals.ParseIssueEmptyLine, line, "")
 			return nil
 		}
 
@@ -830,31 +814,24 @@ func ConvertSingleLineRusKbd(fileName string, dictObj *modals.DictObjectPlainTex
 	})
 
 	if err != nil {
-		panic(err)
+		return nil, report, err
 	}
 
-	if len(invalidLinesList) > 0 {
-		fmt.Printf("\n--Invalid lines in %s:--\n", srcFile)
-		for idx, line := range invalidLinesList {
-			fmt.Printf("%d. %s\n", idx, line)
-		}
-	}
-
-	err = utils.SaveDictToJSON(distFile, dictObj)
-	if err != nil {
-		panic(err)
-	}
+	return dictObj, report, nil
 }
 
-// ConvertAdyRus1960 processes the 1960 Adyghe explanatory dictionary (plain text).
+// adyRus1960Converter processes the 1960 Adyghe explanatory dictionary (plain text).
 // Similar to ConvertThreeVolumes but includes OCR space-removal between uppercase
 // Cyrillic letters and zero-width character stripping. Keys may contain digits (e.g., "А1").
-func ConvertAdyRus1960(fileName string, dictObj *modals.DictObjectPlainText) {
-	srcFile := fmt.Sprintf("content/phase-01-raw-data/%s", fileName)
-	distFile := fmt.Sprintf("content/phase-02-json-data/%s", strings.TrimSuffix(fileName, ".txt")+".json")
-	invalidLinesList := make([]string, 0)
+type adyRus1960Converter struct{}
 
-	fmt.Printf("Starting conversion (Ady-Rus 1960): %s\n", srcFile)
+func (adyRus1960Converter) Name() string { return "ady-rus-1960" }
+
+func (adyRus1960Converter) Version() int { return 1 }
+
+func (adyRus1960Converter) Convert(r io.Reader, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error) {
+	dictObj := newDictObjectPlainText(desc)
+	report := modals.NewParseReport(desc.FileName)
 
 	// removeFirstWordSpaces collapses spaces between uppercase Cyrillic letters and 'I'.
 	// Fixes OCR artifacts where "АБАДЗЭ" was scanned as "А Б А Д З Э".
@@ -881,7 +858,7 @@ func ConvertAdyRus1960(fileName string, dictObj *modals.DictObjectPlainText) {
 		dictObj.WordsToPlainTextMap[spelling] = append(dictObj.WordsToPlainTextMap[spelling], value)
 	}
 
-	err := utils.ReadFileLineByLine(srcFile, func(line string, index int) error {
+	err := utils.ReadLineByLine(r, func(line string, index int) error {
 		line = strings.TrimSpace(line)
 		line = utils.StripZeroWidthChars(line)
 		line = removeFirstWordSpaces(line)
@@ -892,7 +869,7 @@ func ConvertAdyRus1960(fileName string, dictObj *modals.DictObjectPlainText) {
 		trimmedLine = formatNumberDotsStartAware(trimmedLine)
 
 		if len(words) == 0 {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("Empty line %d", index))
+			report.AddIssue(index+1, modals.ParseIssueEmptyLine, line, "")
 		} else if utils.IsFullyCapitalized(words[0]) && !utils.StartsWithSpecialCharacter(words[0]) {
 			flushEntry()
 			currentKey = utils.RemoveSuffixes(words[0])
@@ -912,41 +889,34 @@ func ConvertAdyRus1960(fileName string, dictObj *modals.DictObjectPlainText) {
 	})
 
 	if err != nil {
-		panic(err)
+		return nil, report, err
 	}
 
 	flushEntry()
 
-	if len(invalidLinesList) > 0 {
-		fmt.Printf("\n--Invalid lines in %s:--\n", srcFile)
-		for idx, line := range invalidLinesList {
-			fmt.Printf("%d. %s\n", idx, line)
-		}
-	}
-
-	err = utils.SaveDictToJSON(distFile, dictObj)
-	if err != nil {
-		panic(err)
-	}
+	return dictObj, report, nil
 }
 
-// ConvertSingleLineKbdRu processes the 2008 Kabardian-Russian dictionary (plain text).
+// singleLineKbdRuConverter processes the 2008 Kabardian-Russian dictionary (plain text).
 // Each line is a single entry. The first word is the Kabardian key.
 // Polachka conversion is applied to both key and value.
-func ConvertSingleLineKbdRu(fileName string, dictObj *modals.DictObjectPlainText) {
-	srcFile := fmt.Sprintf("content/phase-01-raw-data/%s", fileName)
-	distFile := fmt.Sprintf("content/phase-02-json-data/%s", strings.TrimSuffix(fileName, ".txt")+".json")
-	invalidLinesList := make([]string, 0)
+type singleLineKbdRuConverter struct{}
 
-	fmt.Printf("Starting conversion (Kbd-Ru Single Line): %s\n", srcFile)
+func (singleLineKbdRuConverter) Name() string { return "single-line-kbd-ru" }
 
-	err := utils.ReadFileLineByLine(srcFile, func(line string, index int) error {
+func (singleLineKbdRuConverter) Version() int { return 1 }
+
+func (singleLineKbdRuConverter) Convert(r io.Reader, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error) {
+	dictObj := newDictObjectPlainText(desc)
+	report := modals.NewParseReport(desc.FileName)
+
+	err := utils.ReadLineByLine(r, func(line string, index int) error {
 		line = strings.TrimSpace(line)
 		line = utils.StripZeroWidthChars(line)
 
 		words := strings.Fields(line)
 		if len(words) == 0 {
-			invalidLinesList = append(invalidLinesList, fmt.Sprintf("Empty line %d", index))
+			report.AddIssue(index+1, modals.ParseIssueEmptyLine, line, "")
 			return nil
 		}
 
@@ -969,18 +939,275 @@ func ConvertSingleLineKbdRu(fileName string, dictObj *modals.DictObjectPlainText
 	})
 
 	if err != nil {
-		panic(err)
+		return nil, report, err
 	}
 
-	if len(invalidLinesList) > 0 {
-		fmt.Printf("\n--Invalid lines in %s:--\n", srcFile)
-		for idx, line := range invalidLinesList {
-			fmt.Printf("%d. %s\n", idx, line)
+	return dictObj, report, nil
+}
+
+// xdxfConverter imports XDXF dictionaries (the logical format of the XDXF standard and
+// the older visual format) as structured entries, so dictionaries received as XDXF can
+// join the pipeline. Its manifest entries use "format": "json". Every <ar> becomes a
+// WordObject for each of its <k> keys: <gr> gives the Type, every <def> (or the text
+// of a visual article) a definition, <ex> its examples, <kref type="syn"> synonyms,
+// another <kref> the redirect, <etm> the derivation, and the <co> comments written by
+// the xdxf export (xdxfCognate) the cognates. Bold text keeps its |bold| markers.
+// The file must be UTF-8.
+type xdxfConverter struct{}
+
+func (xdxfConverter) Name() string { return "xdxf" }
+
+func (xdxfConverter) Version() int { return 2 }
+
+// xdxfCognateRegex matches the <co> comment of a cognate, see xdxfCognate.
+var xdxfCognateRegex = regexp.MustCompile(`^cognate(?: \((.+?)\))?: (.+)$`)
+
+func (xdxfConverter) Convert(r io.Reader, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error) {
+	dictObj := newDictObjectJsonObj(desc)
+	report := modals.NewParseReport(desc.FileName)
+	circassianKeys := strings.ToLower(dictObj.FromLang) == "ady" || strings.ToLower(dictObj.FromLang) == "kbd"
+
+	decoder := xml.NewDecoder(r)
+	decoder.Entity = xml.HTMLEntity
+	articles := 0
+	for {
+		line, _ := decoder.InputPos()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, report, fmt.Errorf("failed to parse XDXF: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "ar" {
+			continue
+		}
+
+		ar, err := readXDXFNode(decoder, start)
+		if err != nil {
+			return nil, report, fmt.Errorf("failed to parse XDXF article at line %d: %w", line, err)
+		}
+		keys, wordObj := xdxfArticle(ar)
+		if len(keys) == 0 || (len(wordObj.Definitions) == 0 && len(wordObj.Synonyms) == 0 && wordObj.Redirect == "") {
+			report.AddIssue(line, modals.ParseIssueEmptyArticle, strings.Join(strings.Fields(ar.text()), " "), "")
+			continue
+		}
+
+		// The "1" and Ӏ spellings of a key are the same key once normalized
+		normalized := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if circassianKeys {
+				key = utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(key)
+			}
+			if key = strings.ToLower(key); !slices.Contains(normalized, key) {
+				normalized = append(normalized, key)
+			}
+		}
+
+		// The keys of an article share its entry, so merging builds a new one instead of
+		// appending to an entry that other keys may hold
+		for _, key := range normalized {
+			existing, exists := dictObj.WordsToJsonObjMap[key]
+			if !exists {
+				dictObj.WordsToJsonObjMap[key] = wordObj
+				continue
+			}
+			merged := *existing
+			merged.Definitions = slices.Concat(existing.Definitions, wordObj.Definitions)
+			merged.Cognates = slices.Concat(existing.Cognates, wordObj.Cognates)
+			merged.Synonyms = slices.Concat(existing.Synonyms, wordObj.Synonyms)
+			dictObj.WordsToJsonObjMap[key] = &merged
+		}
+
+		articles++
+		if articles%1000 == 0 {
+			fmt.Printf("Processed article %d...\n", articles)
 		}
 	}
 
-	err = utils.SaveDictToJSON(distFile, dictObj)
-	if err != nil {
-		panic(err)
+	return dictObj, report, nil
+}
+
+// xdxfNode is an element of an XDXF article. Children are *xdxfNode or string.
+type xdxfNode struct {
+	name     string
+	attrs    []xml.Attr
+	children []any
+}
+
+// readXDXFNode reads the element opened by start, up to its end tag.
+func readXDXFNode(decoder *xml.Decoder, start xml.StartElement) (*xdxfNode, error) {
+	node := &xdxfNode{name: start.Name.Local, attrs: start.Attr}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := readXDXFNode(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, child)
+		case xml.CharData:
+			node.children = append(node.children, string(t))
+		case xml.EndElement:
+			return node, nil
+		}
+	}
+}
+
+// text returns the text of the node and its descendants.
+func (n *xdxfNode) text() string {
+	var sb strings.Builder
+	for _, child := range n.children {
+		switch c := child.(type) {
+		case string:
+			sb.WriteString(c)
+		case *xdxfNode:
+			sb.WriteString(c.text())
+		}
+	}
+	return sb.String()
+}
+
+// attr returns the value of an attribute, or "".
+func (n *xdxfNode) attr(name string) string {
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// find returns the first descendant with the given name, or nil.
+func (n *xdxfNode) find(name string) *xdxfNode {
+	for _, child := range n.children {
+		if c, ok := child.(*xdxfNode); ok {
+			if c.name == name {
+				return c
+			}
+			if found := c.find(name); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// xdxfArticle returns the keys of an <ar> element and its entry.
+func xdxfArticle(ar *xdxfNode) ([]string, *modals.WordObject) {
+	var keys []string
+	wordObj := modals.NewWordObject("")
+
+	// Text outside the structural elements collects in loose until the next one starts
+	var loose strings.Builder
+	flush := func() {
+		if meaning := cleanXDXFText(loose.String()); meaning != "" {
+			wordObj.AddDefinition(meaning, nil)
+		}
+		loose.Reset()
+	}
+
+	var walk func(n *xdxfNode)
+	walk = func(n *xdxfNode) {
+		for _, child := range n.children {
+			c, ok := child.(*xdxfNode)
+			if !ok {
+				loose.WriteString(strings.NewReplacer("\n", " ", "\t", " ").Replace(child.(string)))
+				continue
+			}
+			switch c.name {
+			case "k":
+				if n == ar {
+					if key := strings.Join(strings.Fields(c.text()), " "); key != "" {
+						keys = append(keys, key)
+					}
+				}
+			case "gr":
+				if wordObj.Type == "" {
+					wordObj.Type = strings.Join(strings.Fields(c.text()), " ")
+				}
+			case "def":
+				flush()
+				walk(c)
+				flush()
+			case "ex":
+				flush()
+				// Without <ex_orig>, the sentence is the text around the translation
+				var sentence, translation string
+				for _, part := range c.children {
+					if p, ok := part.(*xdxfNode); ok && p.name == "ex_tran" {
+						translation = p.text()
+					} else if ok {
+						sentence += p.text()
+					} else if c.find("ex_orig") == nil {
+						sentence += part.(string)
+					}
+				}
+				if len(wordObj.Definitions) == 0 {
+					wordObj.AddDefinition("", nil)
+				}
+				last := &wordObj.Definitions[len(wordObj.Definitions)-1]
+				last.AddExample(cleanXDXFText(sentence), cleanXDXFText(translation))
+			case "sr":
+				for _, ref := range c.children {
+					if kref, ok := ref.(*xdxfNode); ok && kref.name == "kref" {
+						if kref.attr("type") == "syn" {
+							wordObj.AddSynonym(cleanXDXFText(kref.text()), "")
+						} else if wordObj.Redirect == "" {
+							wordObj.Redirect = cleanXDXFText(kref.text())
+						}
+					}
+				}
+			case "etm":
+				wordObj.Derivation = cleanXDXFText(c.text())
+			case "co":
+				// Other comments are part of the definition text
+				if m := xdxfCognateRegex.FindStringSubmatch(cleanXDXFText(c.text())); m != nil {
+					wordObj.AddCognate(m[1], m[2])
+				} else {
+					walk(c)
+				}
+			case "br":
+				loose.WriteString("\n")
+			case "b":
+				loose.WriteString("|")
+				walk(c)
+				loose.WriteString("|")
+			default:
+				walk(c)
+			}
+		}
 	}
+	walk(ar)
+	flush()
+	return keys, wordObj
+}
+
+// cleanXDXFText collapses the whitespace of XDXF text, drops empty lines and converts
+// its palochka.
+func cleanXDXFText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(strings.Join(lines, "\n"))
+}
+
+// isJSONObject reports whether an encoded JSON value is an object.
+func isJSONObject(value json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(value)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// jsonEntryText renders a key/value pair of a JSON dictionary for parse reports.
+func jsonEntryText(key string, value json.RawMessage) string {
+	encodedKey, _ := json.Marshal(key)
+	return string(encodedKey) + ": " + string(value)
 }
@@ -5,13 +5,16 @@ import (
 	"fmt"
 	"html"
 	"learn-circassian-helper/modals"
-	"learn-circassian-helper/utils"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 )
 
+// htmlRendererVersion must be bumped whenever a change alters the Phase 03 HTML,
+// so the build cache re-renders every dictionary.
+const htmlRendererVersion = 1
+
 var pipeMarkerRegex = regexp.MustCompile(`\|([^|]+)\|`)
 
 // formatTextWithBoldMarkers converts |text| markers into bold HTML spans.
@@ -88,99 +91,118 @@ func wordObjectToHTML(key string, w *modals.WordObject) string {
 	return sb.String()
 }
 
-// CallConvertPhase02ToPhase03 reads all Phase 02 JSON files and converts their
-// values into HTML format, outputting Phase 03 files. HTML-format dicts are
-// copied as-is. Plain and JSON formats are converted to HTML.
-func CallConvertPhase02ToPhase03() {
-	srcDir := "content/phase-02-json-data"
-	distDir := "content/phase-03-html-data"
-
-	if err := os.MkdirAll(distDir, 0755); err != nil {
-		panic(fmt.Sprintf("Failed to create output directory: %v", err))
+// CallConvertPhase02ToPhase03 reads the Phase 02 JSON file of every selected
+// dictionary and converts its values into HTML format, outputting Phase 03 files.
+// HTML-format dicts are copied as-is. Plain and JSON formats are converted to HTML.
+// Up to Config.Jobs dictionaries are converted at a time; those that already failed
+// in this run are skipped.
+func CallConvertPhase02ToPhase03(run *PipelineRun) error {
+	dictionaries, err := run.Config.SelectDictionaries(run.Manifest)
+	if err != nil {
+		return err
 	}
 
-	entries, err := os.ReadDir(srcDir)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to read source directory: %v", err))
+	if err := os.MkdirAll(run.Config.HTMLDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
-			continue
+	defer saveBuildCache(run.Cache)
+
+	err = forEachDictionary(run.Config.Jobs, dictionaries, func(_ int, desc modals.DictionaryDescriptor) error {
+		if run.Summary.HasFailed(desc.Id) {
+			run.Summary.Skip(desc, "html", "earlier step failed")
+			return nil
 		}
 
-		filePath := filepath.Join(srcDir, entry.Name())
-		distPath := filepath.Join(distDir, entry.Name())
+		fileName := Phase02FileName(desc)
+		srcPath := filepath.Join(run.Config.JSONDir(), fileName)
+		distPath := filepath.Join(run.Config.HTMLDir(), fileName)
 
-		data, err := os.ReadFile(filePath)
-		if err != nil {
-			fmt.Printf("Error reading %s: %s\n", filePath, err)
-			continue
+		if run.Config.useBuildCache() && run.Cache.HTMLUpToDate(desc.Id, srcPath, distPath) {
+			fmt.Printf("Up to date, skipping Phase 03: %s\n", fileName)
+			run.Summary.Cached(desc, "html")
+			return nil
 		}
 
-		// Detect format from JSON
-		var formatDetector struct {
-			Format modals.DictFormat `json:"format"`
+		err := convertPhase02File(run.Config, srcPath, distPath)
+		if err == nil {
+			err = run.Cache.RecordHTML(desc.Id, srcPath, distPath)
 		}
-		if err := json.Unmarshal(data, &formatDetector); err != nil {
-			fmt.Printf("Error parsing format from %s: %s\n", filePath, err)
-			continue
+		if err != nil {
+			return run.Summary.Fail(desc, "html", err)
 		}
+		run.Summary.Succeeded(desc, "html")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		fmt.Printf("Converting to Phase 03: %s (format: %d)\n", entry.Name(), formatDetector.Format)
+	fmt.Println("Phase 02 → Phase 03 conversion complete.")
+	return nil
+}
 
-		switch formatDetector.Format {
-		case modals.DictFormatHTML:
-			// Already HTML — pass values through as-is
-			var dictObj modals.DictObjectPlainText
-			if err := json.Unmarshal(data, &dictObj); err != nil {
-				fmt.Printf("Error parsing %s: %s\n", filePath, err)
-				continue
-			}
-			htmlDict := modals.NewDictObjectHTML(dictObj.Title, dictObj.Id, dictObj.FromLang, dictObj.ToLang)
-			for key, values := range dictObj.WordsToPlainTextMap {
-				htmlDict.WordsToHtmlMap[key] = values
-			}
-			if err := utils.SaveDictToJSON(distPath, htmlDict); err != nil {
-				panic(err)
-			}
+// convertPhase02File converts one Phase 02 JSON file into its Phase 03 HTML file.
+func convertPhase02File(config PipelineConfig, filePath string, distPath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
 
-		case modals.DictFormatPlain:
-			var dictObj modals.DictObjectPlainText
-			if err := json.Unmarshal(data, &dictObj); err != nil {
-				fmt.Printf("Error parsing %s: %s\n", filePath, err)
-				continue
-			}
-			htmlDict := modals.NewDictObjectHTML(dictObj.Title, dictObj.Id, dictObj.FromLang, dictObj.ToLang)
-			for key, values := range dictObj.WordsToPlainTextMap {
-				htmlValues := make([]string, len(values))
-				for i, val := range values {
-					htmlValues[i] = meaningToHTML(val)
-				}
-				htmlDict.WordsToHtmlMap[key] = htmlValues
-			}
-			if err := utils.SaveDictToJSON(distPath, htmlDict); err != nil {
-				panic(err)
-			}
+	// Detect format from JSON
+	var formatDetector struct {
+		Format modals.DictFormat `json:"format"`
+	}
+	if err := json.Unmarshal(data, &formatDetector); err != nil {
+		return fmt.Errorf("failed to parse format from %s: %w", filePath, err)
+	}
 
-		case modals.DictFormatJSON:
-			var dictObj modals.DictObjectJsonObj
-			if err := json.Unmarshal(data, &dictObj); err != nil {
-				fmt.Printf("Error parsing %s: %s\n", filePath, err)
-				continue
-			}
-			htmlDict := modals.NewDictObjectHTML(dictObj.Title, dictObj.Id, dictObj.FromLang, dictObj.ToLang)
-			for key, wordObj := range dictObj.WordsToJsonObjMap {
-				htmlDict.WordsToHtmlMap[key] = []string{wordObjectToHTML(key, wordObj)}
-			}
-			if err := utils.SaveDictToJSON(distPath, htmlDict); err != nil {
-				panic(err)
+	fmt.Printf("Converting to Phase 03: %s (format: %d)\n", filepath.Base(filePath), formatDetector.Format)
+
+	var htmlDict *modals.DictObjectHTML
+
+	switch formatDetector.Format {
+	case modals.DictFormatHTML:
+		// Already HTML — pass values through as-is
+		var dictObj modals.DictObjectPlainText
+		if err := json.Unmarshal(data, &dictObj); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filePath, err)
+		}
+		htmlDict = modals.NewDictObjectHTML(dictObj.Title, dictObj.Id, dictObj.FromLang, dictObj.ToLang)
+		for key, values := range dictObj.WordsToPlainTextMap {
+			htmlDict.WordsToHtmlMap[key] = values
+		}
+
+	case modals.DictFormatPlain:
+		var dictObj modals.DictObjectPlainText
+		if err := json.Unmarshal(data, &dictObj); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filePath, err)
+		}
+		htmlDict = modals.NewDictObjectHTML(dictObj.Title, dictObj.Id, dictObj.FromLang, dictObj.ToLang)
+		for key, values := range dictObj.WordsToPlainTextMap {
+			htmlValues := make([]string, len(values))
+			for i, val := range values {
+				htmlValues[i] = meaningToHTML(val)
 			}
+			htmlDict.WordsToHtmlMap[key] = htmlValues
+		}
 
-		default:
-			fmt.Printf("Unknown format %d in %s, skipping\n", formatDetector.Format, filePath)
+	case modals.DictFormatJSON:
+		var dictObj modals.DictObjectJsonObj
+		if err := json.Unmarshal(data, &dictObj); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filePath, err)
 		}
+		htmlDict = modals.NewDictObjectHTML(dictObj.Title, dictObj.Id, dictObj.FromLang, dictObj.ToLang)
+		for key, wordObj := range dictObj.WordsToJsonObjMap {
+			htmlDict.WordsToHtmlMap[key] = []string{wordObjectToHTML(key, wordObj)}
+		}
+
+	default:
+		return fmt.Errorf("unknown format %d in %s", formatDetector.Format, filePath)
 	}
 
-	fmt.Println("Phase 02 → Phase 03 conversion complete.")
+	if err := savePhaseFile(config, distPath, htmlDict); err != nil {
+		return fmt.Errorf("failed to save %s: %w", distPath, err)
+	}
+	return nil
 }
@@ -1,87 +1,274 @@
 package code
 
 import (
+	"cmp"
+	"container/heap"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"learn-circassian-helper/modals"
 	"learn-circassian-helper/utils"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-// CallConvertPhase03ToPhase04 reads all Phase 03 HTML JSON files and merges
-// them into a single key-value database where each word maps to an array of
-// dictionary entries from different sources.
-func CallConvertPhase03ToPhase04() {
-	srcDir := "content/phase-03-html-data"
-	distDir := "content/phase-04-merged-database"
-	distPath := filepath.Join(distDir, "merged-database.json")
+// MergedDatabaseFileName is the Phase 04 JSON Lines file, one modals.MergedWord per line.
+const MergedDatabaseFileName = "merged-database.jsonl"
 
-	if err := os.MkdirAll(distDir, 0755); err != nil {
-		panic(fmt.Sprintf("Failed to create output directory: %v", err))
+// MergedPhrasesFileName is the Phase 04 JSON Lines file of multi-word and long
+// headwords, in the same format as MergedDatabaseFileName.
+const MergedPhrasesFileName = "merged-phrases.jsonl"
+
+// maxWordRunes is the longest headword kept in the words table; longer ones are phrases only.
+const maxWordRunes = 50
+
+// CallConvertPhase03ToPhase04 reads the Phase 03 HTML JSON file of every
+// dictionary in the manifest and merges them into a single key-value database
+// where each word maps to an array of dictionary entries from different sources.
+//
+// The merge streams so memory does not grow with the number of dictionaries: each
+// dictionary is written to its own run file sorted by word (up to Config.Jobs at a
+// time), then the runs are merged into merged-database.jsonl in word order, with the
+// entries of a word in the rank order given by the manifest priority (DictionaryRanks).
+// Multi-word headwords and headwords longer than maxWordRunes also go to
+// merged-phrases.jsonl; short multi-word headwords stay in the words file as well.
+// Dictionaries without a Phase 03 file or that already failed in this run are
+// skipped, so a stale Phase 03 file is not merged; unreadable files are failures.
+func CallConvertPhase03ToPhase04(run *PipelineRun) error {
+	distDir := run.Config.MergedDir()
+	distPath := filepath.Join(distDir, MergedDatabaseFileName)
+	phrasesPath := filepath.Join(distDir, MergedPhrasesFileName)
+	runsDir := filepath.Join(distDir, "runs")
+
+	if err := os.MkdirAll(runsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
 	}
+	defer os.RemoveAll(runsDir)
+
+	// Runs are merged in rank order, which is what orders the entries of a word
+	ranks := DictionaryRanks(run.Manifest)
+	descriptors := slices.SortedFunc(slices.Values(run.Manifest.Dictionaries), func(a, b modals.DictionaryDescriptor) int {
+		return cmp.Compare(ranks[a.Id], ranks[b.Id])
+	})
 
-	entries, err := os.ReadDir(srcDir)
+	infos := make([]*modals.DictionaryInfo, len(descriptors))
+	err := forEachDictionary(run.Config.Jobs, descriptors, func(i int, desc modals.DictionaryDescriptor) error {
+		if run.Summary.HasFailed(desc.Id) {
+			run.Summary.Skip(desc, "merge", "earlier step failed")
+			return nil
+		}
+
+		info, err := writeMergeRun(run.Config, desc, ranks[desc.Id], mergeRunPath(runsDir, i))
+		if errors.Is(err, fs.ErrNotExist) {
+			run.Summary.Skip(desc, "merge", "no phase 03 file")
+			return nil
+		}
+		if err != nil {
+			return run.Summary.Fail(desc, "merge", err)
+		}
+		infos[i] = info
+		return nil
+	})
 	if err != nil {
-		panic(fmt.Sprintf("Failed to read source directory: %v", err))
+		return err
 	}
 
-	merged := make(map[string][]modals.MergedDictEntry)
 	dictionaries := make([]modals.DictionaryInfo, 0)
-	seenDictIDs := make(map[int]bool)
+	runPaths := make([]string, 0)
+	for i, info := range infos {
+		if info != nil {
+			dictionaries = append(dictionaries, *info)
+			runPaths = append(runPaths, mergeRunPath(runsDir, i))
+		}
+	}
 
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
-			continue
+	wordCount, phraseCount, err := mergeRuns(runPaths, distPath, phrasesPath, run.Config.Verify)
+	if err != nil {
+		return fmt.Errorf("failed to merge into %s: %w", distPath, err)
+	}
+	for i, info := range infos {
+		if info != nil {
+			run.Summary.Succeeded(descriptors[i], "merge")
 		}
+	}
+
+	// dictionaries.json lists dictionaries by id; their rank is a field
+	slices.SortFunc(dictionaries, func(a, b modals.DictionaryInfo) int { return cmp.Compare(a.Id, b.Id) })
+	dictsPath := filepath.Join(distDir, "dictionaries.json")
+	if err := savePhaseFile(run.Config, dictsPath, dictionaries); err != nil {
+		return fmt.Errorf("failed to save %s: %w", dictsPath, err)
+	}
 
-		filePath := filepath.Join(srcDir, entry.Name())
+	fmt.Printf("Phase 03 → Phase 04 merge complete. Total words: %d, phrases: %d, dictionaries: %d\n", wordCount, phraseCount, len(dictionaries))
+	return nil
+}
 
-		data, err := os.ReadFile(filePath)
-		if err != nil {
-			fmt.Printf("Error reading %s: %s\n", filePath, err)
-			continue
-		}
+func mergeRunPath(runsDir string, i int) string {
+	return filepath.Join(runsDir, strconv.Itoa(i)+".jsonl")
+}
+
+// writeMergeRun writes the words of one Phase 03 dictionary to runPath as JSON Lines
+// sorted by word, one single-entry modals.MergedWord per line.
+func writeMergeRun(config PipelineConfig, desc modals.DictionaryDescriptor, rank int, runPath string) (*modals.DictionaryInfo, error) {
+	fileName := Phase02FileName(desc)
+	filePath := filepath.Join(config.HTMLDir(), fileName)
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+
+	var dictObj modals.DictObjectHTML
+	if err := json.Unmarshal(data, &dictObj); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+
+	fmt.Printf("Merging into Phase 04: %s (%d words)\n", fileName, len(dictObj.WordsToHtmlMap))
 
-		var dictObj modals.DictObjectHTML
-		if err := json.Unmarshal(data, &dictObj); err != nil {
-			fmt.Printf("Error parsing %s: %s\n", filePath, err)
-			continue
+	w, err := utils.CreateJSONLines(runPath, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", runPath, err)
+	}
+	for _, word := range slices.Sorted(maps.Keys(dictObj.WordsToHtmlMap)) {
+		entry := modals.MergedDictEntry{Id: dictObj.Id, Html: strings.Join(dictObj.WordsToHtmlMap[word], "")}
+		if err := w.Write(modals.MergedWord{Word: word, Entries: []modals.MergedDictEntry{entry}}); err != nil {
+			w.Close()
+			return nil, fmt.Errorf("failed to write %s: %w", runPath, err)
 		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", runPath, err)
+	}
 
-		if !seenDictIDs[dictObj.Id] {
-			seenDictIDs[dictObj.Id] = true
-			dictionaries = append(dictionaries, modals.DictionaryInfo{
-				Id:       dictObj.Id,
-				Title:    dictObj.Title,
-				FromLang: dictObj.FromLang,
-				ToLang:   dictObj.ToLang,
-			})
+	return &modals.DictionaryInfo{
+		Id:       dictObj.Id,
+		Title:    dictObj.Title,
+		FromLang: dictObj.FromLang,
+		ToLang:   dictObj.ToLang,
+		Rank:     rank,
+	}, nil
+}
+
+// mergeRun is the read position in one sorted run file.
+type mergeRun struct {
+	order   int // position of the run, i.e. of its dictionary in rank order
+	dec     *json.Decoder
+	current modals.MergedWord
+}
+
+// next reads the following word of the run. It reports false at the end of the run.
+func (r *mergeRun) next() (bool, error) {
+	r.current = modals.MergedWord{}
+	if err := r.dec.Decode(&r.current); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
 		}
+		return false, err
+	}
+	return true, nil
+}
 
-		fmt.Printf("Merging into Phase 04: %s (%d words)\n", entry.Name(), len(dictObj.WordsToHtmlMap))
+// mergeHeap orders runs by their current word, then by dictionary order.
+type mergeHeap []*mergeRun
 
-		for word, htmlValues := range dictObj.WordsToHtmlMap {
-			if len(word) > 50 {
-				fmt.Printf("  Skipping long key (%d chars): %s\n", len(word), word)
-				continue
-			}
-			merged[word] = append(merged[word], modals.MergedDictEntry{
-				Id:   dictObj.Id,
-				Html: strings.Join(htmlValues, ""),
-			})
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].current.Word != h[j].current.Word {
+		return h[i].current.Word < h[j].current.Word
+	}
+	return h[i].order < h[j].order
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*mergeRun)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// isPhrase reports whether a headword belongs in the phrases file: it has several
+// words or is too long to be a single word.
+func isPhrase(word string) bool {
+	return strings.ContainsFunc(word, unicode.IsSpace) || utf8.RuneCountInString(word) > maxWordRunes
+}
+
+// mergeRuns k-way merges sorted run files into the words and phrases JSON Lines files
+// and returns the number of words and phrases written. Only one line per run is held
+// in memory at a time.
+func mergeRuns(runPaths []string, wordsPath, phrasesPath string, verify bool) (int, int, error) {
+	h := make(mergeHeap, 0, len(runPaths))
+	for order, runPath := range runPaths {
+		f, err := os.Open(runPath)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer f.Close()
+
+		r := &mergeRun{order: order, dec: json.NewDecoder(f)}
+		ok, err := r.next()
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to read %s: %w", runPath, err)
+		}
+		if ok {
+			h = append(h, r)
 		}
 	}
+	heap.Init(&h)
 
-	if err := utils.SaveDictToJSON(distPath, merged); err != nil {
-		panic(err)
+	words, err := utils.CreateJSONLines(wordsPath, verify)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer words.Close()
+	phrases, err := utils.CreateJSONLines(phrasesPath, verify)
+	if err != nil {
+		return 0, 0, err
 	}
+	defer phrases.Close()
 
-	dictsPath := filepath.Join(distDir, "dictionaries.json")
-	if err := utils.SaveDictToJSON(dictsPath, dictionaries); err != nil {
-		panic(err)
+	wordCount, phraseCount := 0, 0
+	for h.Len() > 0 {
+		merged := modals.MergedWord{Word: h[0].current.Word}
+		for h.Len() > 0 && h[0].current.Word == merged.Word {
+			r := h[0]
+			merged.Entries = append(merged.Entries, r.current.Entries...)
+
+			ok, err := r.next()
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to read %s: %w", runPaths[r.order], err)
+			}
+			if ok {
+				heap.Fix(&h, 0)
+			} else {
+				heap.Pop(&h)
+			}
+		}
+
+		if utf8.RuneCountInString(merged.Word) <= maxWordRunes {
+			if err := words.Write(merged); err != nil {
+				return 0, 0, err
+			}
+			wordCount++
+		}
+		if isPhrase(merged.Word) {
+			if err := phrases.Write(merged); err != nil {
+				return 0, 0, err
+			}
+			phraseCount++
+		}
 	}
 
-	fmt.Printf("Phase 03 → Phase 04 merge complete. Total words: %d, dictionaries: %d\n", len(merged), len(dictionaries))
+	if err := words.Close(); err != nil {
+		return 0, 0, err
+	}
+	return wordCount, phraseCount, phrases.Close()
 }
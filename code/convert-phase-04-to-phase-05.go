@@ -3,60 +3,73 @@ package code
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
 	"os"
 	"path/filepath"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
 
-// CallConvertPhase04ToPhase05 reads the merged JSON database and dictionaries
-// metadata from Phase 04 and writes them into a SQLite database with two tables:
-//   - "dictionaries": one row per dictionary source (id, title, from_lang, to_lang)
+// CallConvertPhase04ToPhase05 streams the merged JSON Lines database and reads the
+// dictionaries metadata from Phase 04, and writes them into a SQLite database with two tables:
+//   - "dictionaries": one row per dictionary source (id, title, from_lang, to_lang, rank)
 //   - "words": one row per word, entries stored as JSON array of {id, html} objects
+//     in rank order
+//   - "phrases": multi-word and long headwords with a normalized search form
+//     (utils.NormalizePhrase) and entries in the same format as words
+//   - "phrase_words": links each word of a phrase's search form to the phrase, so a
+//     word lookup can also list the idioms containing it
+//   - "build_info": provenance of the build (timestamp, pipeline version, source
+//     hashes and per-dictionary entry counts at each phase), see collectBuildInfo
 //
 // This normalization avoids repeating dictionary titles and language info in every
 // entry, reducing database size significantly.
-func CallConvertPhase04ToPhase05() {
-	srcDir := "content/phase-04-merged-database"
-	mergedPath := filepath.Join(srcDir, "merged-database.json")
-	dictsPath := filepath.Join(srcDir, "dictionaries.json")
-	distDir := "content/phase-05-sqlite"
+//
+// The build is reproducible: rows are inserted in sorted order (the Phase 04 files
+// are sorted by word, dictionaries by id), the page layout is fixed by explicit
+// pragmas and a final VACUUM, and build_info's built_at is only written when
+// SOURCE_DATE_EPOCH is set. Identical inputs then give identical database bytes.
+func CallConvertPhase04ToPhase05(run *PipelineRun) error {
+	srcDir := run.Config.MergedDir()
+	mergedPath := filepath.Join(srcDir, MergedDatabaseFileName)
+	phrasesPath := filepath.Join(srcDir, MergedPhrasesFileName)
+	distDir := run.Config.SQLiteDir()
 	distPath := filepath.Join(distDir, "dictionary.db")
 
 	if err := os.MkdirAll(distDir, 0755); err != nil {
-		panic(fmt.Sprintf("Failed to create output directory: %v", err))
+		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	// Remove existing DB so we start fresh
 	os.Remove(distPath)
 
-	mergedData, err := os.ReadFile(mergedPath)
+	dictionaries, err := loadMergedDictionaries(run.Config)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to read %s: %v", mergedPath, err))
+		return err
 	}
 
-	dictsData, err := os.ReadFile(dictsPath)
+	db, err := sql.Open("sqlite", distPath)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to read %s: %v", dictsPath, err))
-	}
-
-	var merged map[string][]modals.MergedDictEntry
-	if err := json.Unmarshal(mergedData, &merged); err != nil {
-		panic(fmt.Sprintf("Failed to parse merged JSON: %v", err))
-	}
-
-	var dictionaries []modals.DictionaryInfo
-	if err := json.Unmarshal(dictsData, &dictionaries); err != nil {
-		panic(fmt.Sprintf("Failed to parse dictionaries JSON: %v", err))
+		return fmt.Errorf("failed to open SQLite: %w", err)
 	}
+	defer db.Close()
 
-	db, err := sql.Open("sqlite", distPath)
+	// Fixed instead of library defaults, which could change the file layout. The page
+	// size must be set before the first table is created.
+	_, err = db.Exec(`
+		PRAGMA page_size = 4096;
+		PRAGMA auto_vacuum = NONE;
+		PRAGMA encoding = 'UTF-8';
+		PRAGMA journal_mode = DELETE;
+	`)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to open SQLite: %v", err))
+		return fmt.Errorf("failed to set pragmas: %w", err)
 	}
-	defer db.Close()
 
 	// Create tables: dictionaries for metadata, words for the actual entries
 	_, err = db.Exec(`
@@ -64,32 +77,46 @@ func CallConvertPhase04ToPhase05() {
 			id INTEGER PRIMARY KEY NOT NULL,
 			title TEXT NOT NULL,
 			from_lang TEXT NOT NULL,
-			to_lang TEXT NOT NULL
+			to_lang TEXT NOT NULL,
+			rank INTEGER NOT NULL
 		);
 		CREATE TABLE words (
 			word TEXT PRIMARY KEY NOT NULL,
 			entries TEXT NOT NULL
 		);
 		CREATE INDEX idx_word ON words(word);
+		CREATE TABLE phrases (
+			id INTEGER PRIMARY KEY NOT NULL,
+			phrase TEXT NOT NULL,
+			search TEXT NOT NULL,
+			entries TEXT NOT NULL
+		);
+		CREATE INDEX idx_phrase_search ON phrases(search);
+		CREATE TABLE phrase_words (
+			word TEXT NOT NULL,
+			phrase_id INTEGER NOT NULL REFERENCES phrases(id),
+			PRIMARY KEY (word, phrase_id)
+		);
 	`)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to create tables: %v", err))
+		return fmt.Errorf("failed to create tables: %w", err)
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		panic(fmt.Sprintf("Failed to begin transaction: %v", err))
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback() // no-op once committed
 
 	// Insert dictionaries
-	dictStmt, err := tx.Prepare("INSERT INTO dictionaries (id, title, from_lang, to_lang) VALUES (?, ?, ?, ?)")
+	dictStmt, err := tx.Prepare("INSERT INTO dictionaries (id, title, from_lang, to_lang, rank) VALUES (?, ?, ?, ?, ?)")
 	if err != nil {
-		panic(fmt.Sprintf("Failed to prepare dictionaries statement: %v", err))
+		return fmt.Errorf("failed to prepare dictionaries statement: %w", err)
 	}
 	defer dictStmt.Close()
 
 	for _, d := range dictionaries {
-		if _, err := dictStmt.Exec(d.Id, d.Title, d.FromLang, d.ToLang); err != nil {
+		if _, err := dictStmt.Exec(d.Id, d.Title, d.FromLang, d.ToLang, d.Rank); err != nil {
 			fmt.Printf("Error inserting dictionary %d (%s): %v\n", d.Id, d.Title, err)
 			continue
 		}
@@ -98,27 +125,125 @@ func CallConvertPhase04ToPhase05() {
 	// Insert words
 	wordStmt, err := tx.Prepare("INSERT INTO words (word, entries) VALUES (?, ?)")
 	if err != nil {
-		panic(fmt.Sprintf("Failed to prepare words statement: %v", err))
+		return fmt.Errorf("failed to prepare words statement: %w", err)
 	}
 	defer wordStmt.Close()
 
 	count := 0
-	for word, entries := range merged {
-		entriesJSON, err := json.Marshal(entries)
+	entryCounts := make(map[int]int, len(dictionaries))
+	err = readMergedWords(mergedPath, func(merged modals.MergedWord) error {
+		for _, entry := range merged.Entries {
+			entryCounts[entry.Id]++
+		}
+
+		entriesJSON, err := json.Marshal(merged.Entries)
 		if err != nil {
-			fmt.Printf("Error marshaling entries for %q: %v\n", word, err)
-			continue
+			fmt.Printf("Error marshaling entries for %q: %v\n", merged.Word, err)
+			return nil
 		}
-		if _, err := wordStmt.Exec(word, string(entriesJSON)); err != nil {
-			fmt.Printf("Error inserting %q: %v\n", word, err)
-			continue
+		if _, err := wordStmt.Exec(merged.Word, string(entriesJSON)); err != nil {
+			fmt.Printf("Error inserting %q: %v\n", merged.Word, err)
+			return nil
 		}
 		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	phraseEntryCounts := make(map[int]int, len(dictionaries))
+	phraseCount, err := insertPhrases(tx, phrasesPath, phraseEntryCounts)
+	if err != nil {
+		return err
+	}
+
+	info, err := collectBuildInfo(run, dictionaries, entryCounts, phraseEntryCounts)
+	if err != nil {
+		return fmt.Errorf("failed to collect build info: %w", err)
+	}
+	if err := writeBuildInfo(tx, info); err != nil {
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
-		panic(fmt.Sprintf("Failed to commit: %v", err))
+		return fmt.Errorf("failed to commit: %w", err)
 	}
 
-	fmt.Printf("Phase 04 → Phase 05 complete. SQLite DB: %s (%d words, %d dictionaries)\n", distPath, count, len(dictionaries))
+	// Rebuild the file so free pages and page order do not depend on insertion history
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum: %w", err)
+	}
+
+	fmt.Printf("Phase 04 → Phase 05 complete. SQLite DB: %s (%d words, %d phrases, %d dictionaries, data version %.12s)\n", distPath, count, phraseCount, len(dictionaries), info.DataVersion)
+	return nil
+}
+
+// insertPhrases fills the phrases and phrase_words tables from the merged phrases
+// file and returns the number of phrases inserted. entryCounts is incremented for
+// the dictionary of every phrase entry.
+func insertPhrases(tx *sql.Tx, phrasesPath string, entryCounts map[int]int) (int, error) {
+	phraseStmt, err := tx.Prepare("INSERT INTO phrases (id, phrase, search, entries) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare phrases statement: %w", err)
+	}
+	defer phraseStmt.Close()
+
+	linkStmt, err := tx.Prepare("INSERT OR IGNORE INTO phrase_words (word, phrase_id) VALUES (?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare phrase_words statement: %w", err)
+	}
+	defer linkStmt.Close()
+
+	count := 0
+	err = readMergedWords(phrasesPath, func(merged modals.MergedWord) error {
+		for _, entry := range merged.Entries {
+			entryCounts[entry.Id]++
+		}
+
+		entriesJSON, err := json.Marshal(merged.Entries)
+		if err != nil {
+			fmt.Printf("Error marshaling entries for phrase %q: %v\n", merged.Word, err)
+			return nil
+		}
+
+		id := count + 1
+		search := utils.NormalizePhrase(merged.Word)
+		if _, err := phraseStmt.Exec(id, merged.Word, search, string(entriesJSON)); err != nil {
+			fmt.Printf("Error inserting phrase %q: %v\n", merged.Word, err)
+			return nil
+		}
+		for _, word := range strings.Fields(search) {
+			if _, err := linkStmt.Exec(word, id); err != nil {
+				return fmt.Errorf("failed to link %q to phrase %q: %w", word, merged.Word, err)
+			}
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// readMergedWords streams a Phase 04 JSON Lines file, decoding one modals.MergedWord
+// at a time so memory stays flat however large the database is.
+func readMergedWords(path string, fn func(merged modals.MergedWord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for line := 1; ; line++ {
+		var merged modals.MergedWord
+		if err := dec.Decode(&merged); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to parse %s at line %d: %w", path, line, err)
+		}
+		if err := fn(merged); err != nil {
+			return err
+		}
+	}
 }
@@ -0,0 +1,153 @@
+package code
+
+import (
+	"database/sql"
+	"io/fs"
+	"learn-circassian-helper/utils"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestDictionaryDBReproducible builds dictionary.db from two copies of the same
+// fixture, whose files have different modification times, with different --jobs, and
+// checks that the two files have the same SHA-256 without SOURCE_DATE_EPOCH.
+func TestDictionaryDBReproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+
+	firstDir := copyTestFixture(t)
+	setModTimes(t, firstDir, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	first, err := utils.HashFile(testDatabasePath(runTestPipeline(t, firstDir, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondDir := copyTestFixture(t)
+	setModTimes(t, secondDir, time.Date(2025, 9, 30, 8, 15, 0, 0, time.UTC))
+	dbPath := testDatabasePath(runTestPipeline(t, secondDir, 4))
+	second, err := utils.HashFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatalf("two builds of the same input differ: %s and %s", first, second)
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var dated int
+	if err := db.QueryRow("SELECT count(*) FROM build_info WHERE key = 'built_at'").Scan(&dated); err != nil {
+		t.Fatal(err)
+	}
+	if dated != 0 {
+		t.Error("built_at is recorded without SOURCE_DATE_EPOCH")
+	}
+
+	// Dictionary 34 has no raw file in the fixture, so it is recorded but not merged
+	for id, want := range map[int]string{3: buildStatusMerged, 4: buildStatusMerged, 7: buildStatusMerged, 34: buildStatusSkipped} {
+		var status string
+		if err := db.QueryRow("SELECT value FROM build_info WHERE dictionary_id = ? AND key = 'status'", id).Scan(&status); err != nil {
+			t.Fatalf("dictionary %d: %v", id, err)
+		}
+		if status != want {
+			t.Errorf("dictionary %d: status = %q, want %q", id, status, want)
+		}
+	}
+
+	want, err := utils.HashFile(filepath.Join("testdata", "pipeline", "phase-01-raw-data", "03-Ady-En.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sourceHash string
+	if err := db.QueryRow("SELECT value FROM build_info WHERE dictionary_id = 3 AND key = 'source_sha256'").Scan(&sourceHash); err != nil {
+		t.Fatal(err)
+	}
+	if sourceHash != want {
+		t.Errorf("source_sha256 = %s, want the hash of the Phase 01 file %s", sourceHash, want)
+	}
+}
+
+func TestDictionaryDBBuiltAt(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1714564800")
+
+	db, err := sql.Open("sqlite", testDatabasePath(buildTestPipeline(t, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var builtAt string
+	if err := db.QueryRow("SELECT value FROM build_info WHERE key = 'built_at'").Scan(&builtAt); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-05-01T12:00:00Z"; builtAt != want {
+		t.Errorf("built_at = %s, want SOURCE_DATE_EPOCH %s", builtAt, want)
+	}
+}
+
+// buildTestPipeline runs the convert to sqlite steps on a copy of testdata/pipeline.
+// Exports can then run on the returned run.
+func buildTestPipeline(t *testing.T, jobs int) *PipelineRun {
+	t.Helper()
+	return runTestPipeline(t, copyTestFixture(t), jobs)
+}
+
+// copyTestFixture copies testdata/pipeline to a new directory and returns it.
+func copyTestFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, os.DirFS(filepath.Join("testdata", "pipeline"))); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// setModTimes sets the modification time of every file under dir.
+func setModTimes(t *testing.T, dir string, modTime time.Time) {
+	t.Helper()
+	err := filepath.WalkDir(dir, func(path string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, modTime, modTime)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runTestPipeline runs the convert to sqlite steps on dictionaries 3, 4 and 7 of a
+// copy of the fixture, which holds their Phase 01 files.
+func runTestPipeline(t *testing.T, dir string, jobs int) *PipelineRun {
+	t.Helper()
+	config := PipelineConfig{InputDir: dir, OutputDir: dir, Only: []int{3, 4, 7}, Jobs: jobs}
+	manifest, err := LoadManifest(config.Manifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &PipelineRun{
+		Config:   config,
+		Manifest: manifest,
+		Summary:  NewPipelineSummary(true),
+		Cache:    LoadBuildCache(config.BuildCachePath()),
+	}
+	for _, step := range []func(*PipelineRun) error{
+		CallConvertPhase01ToPhase02,
+		CallConvertPhase02ToPhase03,
+		CallConvertPhase03ToPhase04,
+		CallConvertPhase04ToPhase05,
+	} {
+		if err := step(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return run
+}
+
+func testDatabasePath(run *PipelineRun) string {
+	return filepath.Join(run.Config.SQLiteDir(), "dictionary.db")
+}
@@ -0,0 +1,79 @@
+package code
+
+import (
+	"fmt"
+	"io"
+	"learn-circassian-helper/modals"
+	"sort"
+	"sync"
+)
+
+// Converter parses one kind of raw (Phase 01) dictionary into its Phase 02 object.
+// Converters for new sources can live in their own package and register
+// themselves from an init function:
+//
+//	func init() { code.RegisterConverter(myConverter{}) }
+type Converter interface {
+	// Name is the identifier used in the manifest's "converter" field.
+	Name() string
+
+	// Version must be bumped whenever a change alters the converter's output,
+	// so the build cache re-converts dictionaries that use it.
+	Version() int
+
+	// Convert reads the raw dictionary from r. Lines that cannot be parsed are
+	// recorded in the report; the error is reserved for failures that make the
+	// whole dictionary unusable (e.g. read errors).
+	Convert(r io.Reader, desc modals.DictionaryDescriptor) (modals.Dictionary, *modals.ParseReport, error)
+}
+
+// FormsExtractor is implemented by converters whose sources list the inflected forms
+// of a headword, so exports can index them (e.g. Kindle idx:infl). Forms receives a
+// Phase 02 key and one of its plain-text values and returns the forms, normalized
+// like keys, without the key itself.
+type FormsExtractor interface {
+	Forms(key, value string) []string
+}
+
+var (
+	convertersMu sync.RWMutex
+	converters   = make(map[string]Converter)
+)
+
+// RegisterConverter makes a converter available by name. It panics if the name
+// is empty or already registered, as that is always a programming error.
+func RegisterConverter(converter Converter) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+
+	name := converter.Name()
+	if name == "" {
+		panic("RegisterConverter: converter name is empty")
+	}
+	if _, exists := converters[name]; exists {
+		panic(fmt.Sprintf("RegisterConverter: converter %q registered twice", name))
+	}
+	converters[name] = converter
+}
+
+// LookupConverter returns the converter registered under the given name.
+func LookupConverter(name string) (Converter, bool) {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+
+	converter, ok := converters[name]
+	return converter, ok
+}
+
+// ConverterNames returns the names of all registered converters, sorted.
+func ConverterNames() []string {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+
+	names := make([]string, 0, len(converters))
+	for name := range converters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,613 @@
+package code
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Note type ids are fixed so rebuilt decks update the note types users already have.
+const (
+	ankiWordModelID  int64 = 1700000000001
+	ankiClozeModelID int64 = 1700000000002
+)
+
+// ankiCSS styles the cards of both note types.
+const ankiCSS = `.card { font-family: Arial, sans-serif; font-size: 20px; text-align: center; }
+.word { font-size: 32px; }
+.type { font-style: italic; color: #666; }
+.gloss, .translation { text-align: left; }
+.cloze { font-weight: bold; color: #1565c0; }
+.source { font-size: 12px; color: #999; margin-top: 1em; }
+`
+
+// ankiSchema is the collection schema (version 11) of the .apkg format that every
+// Anki version imports.
+const ankiSchema = `
+CREATE TABLE col (
+	id integer PRIMARY KEY, crt integer NOT NULL, mod integer NOT NULL, scm integer NOT NULL,
+	ver integer NOT NULL, dty integer NOT NULL, usn integer NOT NULL, ls integer NOT NULL,
+	conf text NOT NULL, models text NOT NULL, decks text NOT NULL, dconf text NOT NULL, tags text NOT NULL
+);
+CREATE TABLE notes (
+	id integer PRIMARY KEY, guid text NOT NULL, mid integer NOT NULL, mod integer NOT NULL,
+	usn integer NOT NULL, tags text NOT NULL, flds text NOT NULL, sfld integer NOT NULL,
+	csum integer NOT NULL, flags integer NOT NULL, data text NOT NULL
+);
+CREATE TABLE cards (
+	id integer PRIMARY KEY, nid integer NOT NULL, did integer NOT NULL, ord integer NOT NULL,
+	mod integer NOT NULL, usn integer NOT NULL, type integer NOT NULL, queue integer NOT NULL,
+	due integer NOT NULL, ivl integer NOT NULL, factor integer NOT NULL, reps integer NOT NULL,
+	lapses integer NOT NULL, left integer NOT NULL, odue integer NOT NULL, odid integer NOT NULL,
+	flags integer NOT NULL, data text NOT NULL
+);
+CREATE TABLE revlog (
+	id integer PRIMARY KEY, cid integer NOT NULL, usn integer NOT NULL, ease integer NOT NULL,
+	ivl integer NOT NULL, lastIvl integer NOT NULL, factor integer NOT NULL, time integer NOT NULL,
+	type integer NOT NULL
+);
+CREATE TABLE graves (usn integer NOT NULL, oid integer NOT NULL, type integer NOT NULL);
+CREATE INDEX ix_notes_usn ON notes (usn);
+CREATE INDEX ix_cards_usn ON cards (usn);
+CREATE INDEX ix_revlog_usn ON revlog (usn);
+CREATE INDEX ix_cards_nid ON cards (nid);
+CREATE INDEX ix_cards_sched ON cards (did, queue, due);
+CREATE INDEX ix_revlog_cid ON revlog (cid);
+CREATE INDEX ix_notes_csum ON notes (csum);
+`
+
+// CallExportAnki writes every selected dictionary as an Anki deck, exports/anki/<name>.apkg,
+// named "Circassian::<From>-<To>::<title>". Decks are selected with --only and --pair,
+// and ExportOptions.WordList limits them to the words of a list.
+//
+// Every key becomes a "Circassian Word" note (word → gloss). Structured (JSON) sources
+// add a "Circassian Cloze" note per example sentence that contains the word, with the
+// word as the cloze deletion. Note GUIDs are hashes of the dictionary id, the key and
+// the sentence, so importing a rebuilt deck updates the notes and keeps their reviews.
+func CallExportAnki(run *PipelineRun) error {
+	dictionaries, err := selectExportDictionaries(run)
+	if err != nil {
+		return err
+	}
+	distDir := run.Config.ExportDir("anki")
+	if err := os.MkdirAll(distDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var wordList map[string]bool
+	if run.Config.Export.WordList != "" {
+		if wordList, err = readAnkiWordList(run.Config.Export.WordList); err != nil {
+			return err
+		}
+	}
+
+	// Anki needs modification times. As in dictionary.db, the only one is
+	// SOURCE_DATE_EPOCH, else a fixed time, so rebuilds of the same data are identical
+	modified, err := sourceDateEpoch()
+	if err != nil {
+		return err
+	}
+	if modified.IsZero() {
+		modified = exportZipTime
+	}
+
+	err = forEachExportDictionary(run, "anki", dictionaries, func(_ int, d exportDictionary) error {
+		return exportAnkiDeck(run.Config, d, wordList, modified, distDir)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Anki export complete: %d decks in %s\n", len(dictionaries), distDir)
+	return nil
+}
+
+// readAnkiWordList reads a word list, one word or phrase per line, in the search form
+// of utils.NormalizePhrase. Empty lines and lines starting with # are skipped.
+func readAnkiWordList(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read word list: %w", err)
+	}
+	defer f.Close()
+
+	words := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words[utils.NormalizePhrase(line)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read word list: %w", err)
+	}
+	return words, nil
+}
+
+// ankiNote is a note of a deck with its fields in note type order.
+type ankiNote struct {
+	guid   string
+	model  int64
+	fields []string
+}
+
+// exportAnkiDeck writes the .apkg of one dictionary.
+func exportAnkiDeck(config PipelineConfig, d exportDictionary, wordList map[string]bool, modified time.Time, distDir string) error {
+	title := html.EscapeString(d.Title)
+	var notes []ankiNote
+	clozes := 0
+	seen := make(map[string]bool) // GUIDs, as sources repeat examples
+
+	source, err := readExportSource(config, d)
+	if err != nil {
+		return err
+	}
+	if source.Structured {
+		swapped := ankiExamplesSwapped(source.Words)
+		for _, key := range slices.Sorted(maps.Keys(source.Words)) {
+			w := source.Words[key]
+			if wordList != nil && !wordList[utils.NormalizePhrase(key)] {
+				continue
+			}
+			word := html.EscapeString(utils.ConvertCircassian1ToPalochka(key))
+			if gloss := wordObjectToAnkiGloss(w); gloss != "" {
+				notes = append(notes, ankiNote{
+					guid:   ankiGUID(d.Id, "word", key),
+					model:  ankiWordModelID,
+					fields: []string{word, gloss, html.EscapeString(w.Type), title},
+				})
+			}
+			for _, def := range w.Definitions {
+				for _, ex := range def.Examples {
+					guid := ankiGUID(d.Id, "cloze", key, ex.Sentence)
+					if swapped {
+						ex.Sentence, ex.Translation = ex.Translation, ex.Sentence
+					}
+					text, ok := ankiCloze(ex.Sentence, key)
+					if !ok || seen[guid] {
+						continue
+					}
+					seen[guid] = true
+					notes = append(notes, ankiNote{
+						guid:   guid,
+						model:  ankiClozeModelID,
+						fields: []string{text, ankiBoldMarkers(ex.Translation), word, title},
+					})
+					clozes++
+				}
+			}
+		}
+	} else {
+		for _, key := range slices.Sorted(maps.Keys(source.HTML)) {
+			if wordList != nil && !wordList[utils.NormalizePhrase(key)] {
+				continue
+			}
+			gloss := strings.Join(source.HTML[key], "")
+			if gloss == "" {
+				continue
+			}
+			notes = append(notes, ankiNote{
+				guid:   ankiGUID(d.Id, "word", key),
+				model:  ankiWordModelID,
+				fields: []string{html.EscapeString(utils.ConvertCircassian1ToPalochka(key)), gloss, "", title},
+			})
+		}
+	}
+
+	apkgPath := filepath.Join(distDir, d.Name+".apkg")
+	if len(notes) == 0 {
+		// A deck without notes would only clutter Anki; drop the one of an earlier run
+		if err := os.Remove(apkgPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", apkgPath, err)
+		}
+		fmt.Printf("  %s: no notes, skipped\n", filepath.Base(apkgPath))
+		return nil
+	}
+
+	collectionPath := filepath.Join(distDir, d.Name+".anki2.tmp")
+	defer os.Remove(collectionPath)
+	if err := writeAnkiCollection(collectionPath, d, notes, modified); err != nil {
+		return err
+	}
+	collection, err := os.ReadFile(collectionPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", collectionPath, err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{
+		{"collection.anki2", collection},
+		{"media", []byte("{}")}, // no media files
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: exportZipTime})
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", file.name, err)
+		}
+		if _, err := w.Write(file.data); err != nil {
+			return fmt.Errorf("failed to add %s: %w", file.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write ZIP: %w", err)
+	}
+	if err := os.WriteFile(apkgPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", apkgPath, err)
+	}
+	fmt.Printf("  %s: %d notes (%d cloze)\n", filepath.Base(apkgPath), len(notes), clozes)
+	return nil
+}
+
+// writeAnkiCollection writes the collection database of a deck. Like the Phase 05
+// database it is reproducible: its only timestamp is modified.
+func writeAnkiCollection(path string, d exportDictionary, notes []ankiNote, modified time.Time) error {
+	os.Remove(path)
+	mod := modified.Unix()
+	deckID := ankiID(fmt.Sprintf("deck:%d", d.Id))
+
+	conf, models, decks, dconf, err := ankiCollectionConfig(d, deckID, mod)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("failed to open SQLite: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("PRAGMA page_size = 4096; PRAGMA auto_vacuum = NONE; PRAGMA journal_mode = DELETE;"); err != nil {
+		return fmt.Errorf("failed to set pragmas: %w", err)
+	}
+	if _, err := db.Exec(ankiSchema); err != nil {
+		return fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() // no-op once committed
+
+	_, err = tx.Exec("INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')",
+		mod, mod*1000, mod*1000, conf, models, decks, dconf)
+	if err != nil {
+		return fmt.Errorf("failed to insert collection: %w", err)
+	}
+
+	noteStmt, err := tx.Prepare("INSERT INTO notes VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')")
+	if err != nil {
+		return fmt.Errorf("failed to prepare notes statement: %w", err)
+	}
+	defer noteStmt.Close()
+	cardStmt, err := tx.Prepare("INSERT INTO cards VALUES (?, ?, ?, 0, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')")
+	if err != nil {
+		return fmt.Errorf("failed to prepare cards statement: %w", err)
+	}
+	defer cardStmt.Close()
+
+	tags := " " + languagePairFileName(d.Desc.LanguagePair()) + " "
+	for i, note := range notes {
+		// Ids only need to be unique; Anki matches imported notes by GUID
+		noteID := ankiID(note.guid)
+		sortField := ankiStripHTML(note.fields[0])
+		_, err := noteStmt.Exec(noteID, note.guid, note.model, mod, tags, strings.Join(note.fields, "\x1f"), sortField, ankiChecksum(sortField))
+		if err != nil {
+			return fmt.Errorf("failed to insert note %q: %w", sortField, err)
+		}
+		if _, err := cardStmt.Exec(noteID, noteID, deckID, mod, i+1); err != nil {
+			return fmt.Errorf("failed to insert card of %q: %w", sortField, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum: %w", err)
+	}
+	return db.Close()
+}
+
+// ankiModel, ankiTemplate and ankiField are the note type JSON of the col table.
+type ankiModel struct {
+	ID        int64          `json:"id"`
+	Name      string         `json:"name"`
+	Type      int            `json:"type"` // 0 standard, 1 cloze
+	Mod       int64          `json:"mod"`
+	Usn       int            `json:"usn"`
+	Sortf     int            `json:"sortf"`
+	Did       int64          `json:"did"`
+	Tmpls     []ankiTemplate `json:"tmpls"`
+	Flds      []ankiField    `json:"flds"`
+	CSS       string         `json:"css"`
+	LatexPre  string         `json:"latexPre"`
+	LatexPost string         `json:"latexPost"`
+	LatexSvg  bool           `json:"latexsvg"`
+	Req       []any          `json:"req"`
+	Tags      []string       `json:"tags"`
+	Vers      []any          `json:"vers"`
+}
+
+type ankiTemplate struct {
+	Name  string `json:"name"`
+	Ord   int    `json:"ord"`
+	Qfmt  string `json:"qfmt"`
+	Afmt  string `json:"afmt"`
+	Bqfmt string `json:"bqfmt"`
+	Bafmt string `json:"bafmt"`
+	Did   *int64 `json:"did"`
+}
+
+type ankiField struct {
+	Name   string   `json:"name"`
+	Ord    int      `json:"ord"`
+	Sticky bool     `json:"sticky"`
+	RTL    bool     `json:"rtl"`
+	Font   string   `json:"font"`
+	Size   int      `json:"size"`
+	Media  []string `json:"media"`
+}
+
+// ankiCollectionConfig returns the conf, models, decks and dconf JSON of the col table.
+func ankiCollectionConfig(d exportDictionary, deckID, mod int64) (conf, models, decks, dconf string, err error) {
+	fields := func(rtl map[string]bool, names ...string) []ankiField {
+		flds := make([]ankiField, len(names))
+		for i, name := range names {
+			flds[i] = ankiField{Name: name, Ord: i, RTL: rtl[name], Font: "Arial", Size: 20, Media: []string{}}
+		}
+		return flds
+	}
+	// Arabic glosses and translations are written right to left
+	rtl := map[string]bool{"Gloss": languageTag(d.ToLang) == "ar", "Translation": languageTag(d.ToLang) == "ar"}
+	latexPre := "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n"
+
+	modelMap := map[string]ankiModel{
+		strconv.FormatInt(ankiWordModelID, 10): {
+			ID: ankiWordModelID, Name: "Circassian Word", Mod: mod, Did: deckID,
+			Tmpls: []ankiTemplate{{
+				Name: "Word → Gloss",
+				Qfmt: `<div class="word">{{Word}}</div>`,
+				Afmt: `{{FrontSide}}<hr id="answer">{{#Type}}<div class="type">{{Type}}</div>{{/Type}}<div class="gloss">{{Gloss}}</div><div class="source">{{Dictionary}}</div>`,
+			}},
+			Flds: fields(rtl, "Word", "Gloss", "Type", "Dictionary"),
+			CSS:  ankiCSS, LatexPre: latexPre, LatexPost: "\\end{document}",
+			Req: []any{[]any{0, "any", []int{0}}}, Tags: []string{}, Vers: []any{},
+		},
+		strconv.FormatInt(ankiClozeModelID, 10): {
+			ID: ankiClozeModelID, Name: "Circassian Cloze", Type: 1, Mod: mod, Did: deckID,
+			Tmpls: []ankiTemplate{{
+				Name: "Cloze",
+				Qfmt: `{{cloze:Text}}`,
+				Afmt: `{{cloze:Text}}<hr id="answer"><div class="translation">{{Translation}}</div><div class="word">{{Word}}</div><div class="source">{{Dictionary}}</div>`,
+			}},
+			Flds: fields(rtl, "Text", "Translation", "Word", "Dictionary"),
+			CSS:  ankiCSS, LatexPre: latexPre, LatexPost: "\\end{document}",
+			Req: []any{[]any{0, "any", []int{0}}}, Tags: []string{}, Vers: []any{},
+		},
+	}
+
+	deck := func(id int64, name, desc string) map[string]any {
+		return map[string]any{
+			"id": id, "name": name, "desc": desc, "mod": mod, "usn": -1, "collapsed": false, "browserCollapsed": false,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+			"dyn": 0, "extendNew": 10, "extendRev": 50, "conf": 1,
+		}
+	}
+	deckMap := map[string]any{
+		"1": deck(1, "Default", ""),
+		strconv.FormatInt(deckID, 10): deck(deckID,
+			fmt.Sprintf("Circassian::%s::%s", languagePairFileName(d.Desc.LanguagePair()), d.Title),
+			fmt.Sprintf("%s, %s to %s. Converted from %s.", html.EscapeString(d.Title), d.FromLang, d.ToLang, html.EscapeString(d.Desc.FileName))),
+	}
+
+	dconfMap := map[string]any{"1": map[string]any{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true, "timer": 0, "replayq": true, "dyn": false,
+		"new":   map[string]any{"delays": []int{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500, "order": 1, "perDay": 20, "bury": false, "separate": true},
+		"rev":   map[string]any{"perDay": 200, "ease4": 1.3, "fuzz": 0.05, "ivlFct": 1, "maxIvl": 36500, "bury": false, "minSpace": 1},
+		"lapse": map[string]any{"delays": []int{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0},
+	}}
+
+	confMap := map[string]any{
+		"nextPos": 1, "estTimes": true, "activeDecks": []int64{deckID}, "sortType": "noteFld", "timeLim": 0,
+		"sortBackwards": false, "addToCur": true, "curDeck": deckID, "newSpread": 0, "dueCounts": true,
+		"curModel": strconv.FormatInt(ankiWordModelID, 10), "collapseTime": 1200,
+	}
+
+	var out [4][]byte
+	for i, v := range []any{confMap, modelMap, deckMap, dconfMap} {
+		if out[i], err = json.Marshal(v); err != nil {
+			return "", "", "", "", fmt.Errorf("failed to encode collection config: %w", err)
+		}
+	}
+	return string(out[0]), string(out[1]), string(out[2]), string(out[3]), nil
+}
+
+// ankiGUIDAlphabet is the base91 alphabet of Anki's own GUIDs.
+const ankiGUIDAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#$%&()*+,-./:;<=>?@[]^_`{|}~"
+
+// ankiGUID returns the stable GUID of a note: the first 64 bits of the SHA-256 of the
+// dictionary id and the given parts, in base91 like Anki's.
+func ankiGUID(dictionaryID int, parts ...string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("learn-circassian:%d:%s", dictionaryID, strings.Join(parts, "\x1f"))))
+	n := binary.BigEndian.Uint64(sum[:8])
+	var guid []byte
+	for n > 0 {
+		guid = append(guid, ankiGUIDAlphabet[n%91])
+		n /= 91
+	}
+	slices.Reverse(guid)
+	return string(guid)
+}
+
+// ankiID returns a stable positive id below 2^53, so it survives Anki's JSON handling.
+func ankiID(s string) int64 {
+	sum := sha256.Sum256([]byte(s))
+	return int64(binary.BigEndian.Uint64(sum[:8]) >> 11)
+}
+
+// ankiChecksum returns Anki's csum of a sort field: the first 32 bits of its SHA-1.
+func ankiChecksum(s string) int64 {
+	sum := sha1.Sum([]byte(s))
+	return int64(binary.BigEndian.Uint32(sum[:4]))
+}
+
+var ankiTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// ankiStripHTML returns the text of a field for the sort field and checksum.
+func ankiStripHTML(field string) string {
+	return html.UnescapeString(ankiTagRegex.ReplaceAllString(field, ""))
+}
+
+// wordObjectToAnkiGloss returns the back of a word note: the numbered definitions, or
+// a pointer to the redirect of an entry without them.
+func wordObjectToAnkiGloss(w *modals.WordObject) string {
+	var sb strings.Builder
+	if len(w.Definitions) > 0 {
+		sb.WriteString("<ol>")
+		for _, def := range w.Definitions {
+			sb.WriteString("<li>" + meaningToHTML(def.Meaning) + "</li>")
+		}
+		sb.WriteString("</ol>")
+	}
+	if w.Redirect != "" {
+		sb.WriteString("→ " + html.EscapeString(utils.ConvertCircassian1ToPalochka(w.Redirect)))
+	}
+	return sb.String()
+}
+
+// ankiBoldMarkers escapes text and turns its |bold| markers into <b>.
+func ankiBoldMarkers(text string) string {
+	return pipeMarkerRegex.ReplaceAllString(html.EscapeString(text), "<b>$1</b>")
+}
+
+// ankiTokenRegex matches the words of a sentence, "1" for palochka included.
+var ankiTokenRegex = regexp.MustCompile(`[\p{L}\p{N}]+(?:-[\p{L}\p{N}]+)*`)
+
+// ankiMinPrefixRunes is the key length from which inflected forms, words starting
+// with the key or the key without its last letter, count as occurrences.
+const ankiMinPrefixRunes = 3
+
+// ankiCloze returns an example sentence with the occurrence of key as the cloze
+// deletion {{c1::…}}: its |bold| parts that match the words of key when it has any,
+// otherwise the first run of words matching them. Other bold parts stay bold. It
+// reports false when the key is not found.
+func ankiCloze(sentence, key string) (string, bool) {
+	sentence = utils.ConvertAllPolachkaLookingLettersTo1InCircassianWords(sentence)
+	display := func(s string) string {
+		return html.EscapeString(utils.ConvertCircassian1ToPalochka(s))
+	}
+	displayBold := func(s string) string {
+		return pipeMarkerRegex.ReplaceAllString(display(s), "<b>$1</b>")
+	}
+
+	keyWords := strings.Fields(utils.NormalizePhrase(key))
+	if len(keyWords) == 0 {
+		return "", false
+	}
+
+	var sb strings.Builder
+	last, clozed := 0, false
+	for _, m := range pipeMarkerRegex.FindAllStringSubmatchIndex(sentence, -1) {
+		bold := sentence[m[2]:m[3]]
+		sb.WriteString(display(sentence[last:m[0]]))
+		if words := ankiTokenRegex.FindAllString(bold, -1); ankiWordsMatch(words, keyWords) {
+			sb.WriteString("{{c1::" + display(bold) + "}}")
+			clozed = true
+		} else {
+			sb.WriteString("<b>" + display(bold) + "</b>")
+		}
+		last = m[1]
+	}
+	if clozed {
+		sb.WriteString(display(sentence[last:]))
+		return sb.String(), true
+	}
+
+	// No bold part is the key: look for it among the words
+	tokens := ankiTokenRegex.FindAllStringIndex(sentence, -1)
+	for i := 0; i+len(keyWords) <= len(tokens); i++ {
+		words := make([]string, len(keyWords))
+		for j, t := range tokens[i : i+len(keyWords)] {
+			words[j] = sentence[t[0]:t[1]]
+		}
+		if ankiWordsMatch(words, keyWords) {
+			start, end := tokens[i][0], tokens[i+len(keyWords)-1][1]
+			return displayBold(sentence[:start]) + "{{c1::" + display(sentence[start:end]) + "}}" + displayBold(sentence[end:]), true
+		}
+	}
+	return "", false
+}
+
+// ankiWordsMatch reports whether words are the words of a key, one for one, each
+// matching its key word (ankiWordMatches).
+func ankiWordsMatch(words, keyWords []string) bool {
+	if len(words) != len(keyWords) {
+		return false
+	}
+	for i, word := range words {
+		if !ankiWordMatches(utils.NormalizePhrase(word), keyWords[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ankiExamplesSwapped reports whether the examples of a source hold the sentences in
+// the key's language in Translation, as some Jonty dictionaries do: whether keys are
+// found in more translations than sentences.
+func ankiExamplesSwapped(words map[string]*modals.WordObject) bool {
+	inSentence, inTranslation := 0, 0
+	for key, w := range words {
+		if w == nil {
+			continue
+		}
+		for _, def := range w.Definitions {
+			for _, ex := range def.Examples {
+				if _, ok := ankiCloze(ex.Sentence, key); ok {
+					inSentence++
+				}
+				if _, ok := ankiCloze(ex.Translation, key); ok {
+					inTranslation++
+				}
+			}
+		}
+	}
+	return inTranslation > inSentence
+}
+
+// ankiWordMatches reports whether a normalized word of a sentence is keyWord or, for
+// longer keys, one of its inflected forms.
+func ankiWordMatches(word, keyWord string) bool {
+	if word == keyWord {
+		return true
+	}
+	if utf8.RuneCountInString(keyWord) < ankiMinPrefixRunes {
+		return false
+	}
+	_, size := utf8.DecodeLastRuneInString(keyWord)
+	return strings.HasPrefix(word, keyWord) || strings.HasPrefix(word, keyWord[:len(keyWord)-size])
+}
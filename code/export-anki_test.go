@@ -0,0 +1,63 @@
+package code
+
+import (
+	"learn-circassian-helper/utils"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnkiCloze(t *testing.T) {
+	tests := []struct {
+		name, sentence, key, want string
+		ok                        bool
+	}{
+		{"bold key", "Сэ |унэ| сиӀ.", "унэ", "Сэ {{c1::унэ}} сиӀ.", true},
+		{"bold inflected form", "|Унэм| сыкӀуащ.", "унэ", "{{c1::Унэм}} сыкӀуащ.", true},
+		{"bold phrase", "Ар |унэ шхуэ| щыӀэщ.", "унэ шхуэ", "Ар {{c1::унэ шхуэ}} щыӀэщ.", true},
+		{"other bold part stays bold", "|Сэ| унэм сыкӀуащ.", "унэ", "<b>Сэ</b> {{c1::унэм}} сыкӀуащ.", true},
+		{"key and other bold parts", "|Сэ| |унэ| сиӀ.", "унэ", "<b>Сэ</b> {{c1::унэ}} сиӀ.", true},
+		{"no bold", "Ар унэщ.", "унэ", "Ар {{c1::унэщ}}.", true},
+		{"short key needs the whole word", "Ар унэщ.", "ар", "{{c1::Ар}} унэщ.", true},
+		{"key not found", "|Сэ| сыкӀуащ.", "унэ", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ankiCloze(tt.sentence, tt.key)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("ankiCloze(%q, %q) = %q, %v, want %q, %v", tt.sentence, tt.key, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+// TestExportAnkiReproducible exports the decks of two builds of the same fixture and
+// checks that they are byte-identical without SOURCE_DATE_EPOCH.
+func TestExportAnkiReproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+
+	hashes := make([]map[string]string, 2)
+	for i := range hashes {
+		run := buildTestPipeline(t, 1)
+		if err := CallExportAnki(run); err != nil {
+			t.Fatal(err)
+		}
+		paths, err := filepath.Glob(filepath.Join(run.Config.ExportDir("anki"), "*.apkg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) == 0 {
+			t.Fatal("no deck exported")
+		}
+		hashes[i] = make(map[string]string)
+		for _, path := range paths {
+			if hashes[i][filepath.Base(path)], err = utils.HashFile(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for name, hash := range hashes[0] {
+		if hashes[1][name] != hash {
+			t.Errorf("%s differs between two exports of the same input", name)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package code
+
+import (
+	"bufio"
+	"fmt"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// appleFrontMatterID is the id of the entry Dictionary.app shows as the front matter.
+const appleFrontMatterID = "front_back_matter"
+
+// appleCSS styles the articles; the merged HTML brings its own inline styles.
+const appleCSS = `@charset "UTF-8";
+@namespace d url(http://www.apple.com/DTDs/DictionaryService-1.0.rng);
+
+d|entry {
+}
+
+h1 {
+	font-size: 150%;
+}
+
+h3 {
+	font-size: 100%;
+	color: #666;
+	margin-top: 1em;
+	border-bottom: 1px solid #ccc;
+}
+`
+
+// CallExportApple writes an Apple Dictionary Development Kit source per language pair
+// of the selected dictionaries to exports/apple/<From>-<To>/: Dictionary.xml with one
+// d:entry per headword, Dictionary.css and Info.plist. Build the .dictionary bundle
+// with the DDK's build_dict.sh on macOS.
+//
+// Every entry is titled with the display spelling with Ӏ and indexed (d:index) under
+// it, its lowercase ӏ and its "1" spelling, plus the inflected forms listed by sources
+// whose converter is a FormsExtractor, so lookups of any spelling find it.
+func CallExportApple(run *PipelineRun) error {
+	dictionaries, err := selectExportDictionaries(run)
+	if err != nil {
+		return err
+	}
+	distDir := run.Config.ExportDir("apple")
+
+	var books []*appleDictionary
+	bookOf := make(map[string]*appleDictionary)
+	for _, d := range dictionaries {
+		pair := d.Desc.LanguagePair()
+		book, ok := bookOf[pair]
+		if !ok {
+			book = &appleDictionary{pair: pair, fromLang: d.FromLang, toLang: d.ToLang, titles: make(map[int]string)}
+			bookOf[pair] = book
+			books = append(books, book)
+		}
+		book.dictionaries = append(book.dictionaries, d)
+		book.titles[d.Id] = d.Title
+	}
+
+	forms, err := loadForms(run.Config, dictionaries)
+	if err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		if err := book.create(distDir); err != nil {
+			return err
+		}
+		defer book.file.Close() // after an error; finish closes it otherwise
+	}
+
+	err = readExportWords(run.Config, func(merged modals.MergedWord) error {
+		for _, book := range books {
+			if article := combinedArticle(merged.Entries, book.titles); article != "" {
+				if err := book.add(merged.Word, article, forms[merged.Word]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		if err := book.finish(); err != nil {
+			return err
+		}
+		for _, d := range book.dictionaries {
+			run.Summary.Succeeded(d.Desc, "apple")
+		}
+	}
+
+	fmt.Printf("Apple dictionary export complete: %d language pairs in %s\n", len(books), distDir)
+	return nil
+}
+
+// appleDictionary is the Dictionary.app source of one language pair.
+type appleDictionary struct {
+	pair             string
+	fromLang, toLang string
+	dictionaries     []exportDictionary
+	titles           map[int]string
+
+	dir     string
+	name    string
+	file    *os.File
+	w       *bufio.Writer
+	entries int
+}
+
+// create empties the dictionary's folder and opens Dictionary.xml.
+func (a *appleDictionary) create(distDir string) error {
+	a.name = languagePairFileName(a.pair)
+	a.dir = filepath.Join(distDir, a.name)
+	if err := os.RemoveAll(a.dir); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", a.dir, err)
+	}
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	path := filepath.Join(a.dir, "Dictionary.xml")
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	a.file, a.w = f, bufio.NewWriter(f)
+	a.w.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	if _, err := a.w.WriteString(`<d:dictionary xmlns="http://www.w3.org/1999/xhtml" xmlns:d="http://www.apple.com/DTDs/DictionaryService-1.0.rng">` + "\n"); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// add writes the d:entry of one headword. A bufio.Writer keeps its first error, so
+// the last write of the entry reports a failure of any earlier one.
+func (a *appleDictionary) add(word, article string, forms []string) error {
+	esc := utils.EscapeXML
+	display := utils.ConvertCircassian1ToPalochka(word)
+	a.entries++
+
+	fmt.Fprintf(a.w, "<d:entry id=\"e%06d\" d:title=\"%s\">\n", a.entries, esc(display))
+	var indexed []string
+	for _, spelling := range append([]string{word}, forms...) {
+		palochka := utils.ConvertCircassian1ToPalochka(spelling)
+		for _, variant := range []string{palochka, strings.ToLower(palochka), spelling} {
+			if slices.Contains(indexed, variant) {
+				continue
+			}
+			indexed = append(indexed, variant)
+			// d:title is shown in the search results, so forms point at the headword
+			fmt.Fprintf(a.w, "<d:index d:value=\"%s\" d:title=\"%s\"/>\n", esc(variant), esc(display))
+		}
+	}
+	if _, err := fmt.Fprintf(a.w, "<h1>%s</h1>\n%s\n</d:entry>\n", esc(display), utils.HTMLToXHTML(article)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", a.file.Name(), err)
+	}
+	return nil
+}
+
+// finish writes the front matter, closes Dictionary.xml and writes the CSS and the plist.
+func (a *appleDictionary) finish() error {
+	esc := utils.EscapeXML
+	title := fmt.Sprintf("Circassian dictionaries %s → %s", a.fromLang, a.toLang)
+
+	fmt.Fprintf(a.w, "<d:entry id=\"%s\" d:title=\"%s\">\n<h1>%s</h1>\n<p>Converted by learn-circassian-helper from:</p>\n<ul>\n",
+		appleFrontMatterID, esc(title), esc(title))
+	for _, d := range a.dictionaries {
+		fmt.Fprintf(a.w, "<li>%s</li>\n", esc(d.Title))
+	}
+	a.w.WriteString("</ul>\n</d:entry>\n</d:dictionary>\n")
+	if err := a.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", a.file.Name(), err)
+	}
+	if err := a.file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", a.file.Name(), err)
+	}
+
+	cssPath := filepath.Join(a.dir, "Dictionary.css")
+	if err := os.WriteFile(cssPath, []byte(appleCSS), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", cssPath, err)
+	}
+
+	titles := make([]string, len(a.dictionaries))
+	for i, d := range a.dictionaries {
+		titles[i] = d.Title
+	}
+	var plist strings.Builder
+	plist.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	plist.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	plist.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	for _, kv := range [][2]string{
+		{"CFBundleDevelopmentRegion", "English"},
+		{"CFBundleIdentifier", "org.learn-circassian." + strings.ToLower(strings.ReplaceAll(a.name, "+", "-"))},
+		{"CFBundleName", title},
+		{"CFBundleShortVersionString", "1.0"},
+		{"DCSDictionaryCopyright", strings.Join(titles, "; ")},
+		{"DCSDictionaryManufacturerName", "learn-circassian-helper"},
+		{"DCSDictionaryFrontMatterReferenceID", appleFrontMatterID},
+	} {
+		fmt.Fprintf(&plist, "\t<key>%s</key>\n\t<string>%s</string>\n", kv[0], esc(kv[1]))
+	}
+	plist.WriteString("</dict>\n</plist>\n")
+
+	plistPath := filepath.Join(a.dir, "Info.plist")
+	if err := os.WriteFile(plistPath, []byte(plist.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", plistPath, err)
+	}
+	fmt.Printf("  %s: %d entries\n", a.name, a.entries)
+	return nil
+}
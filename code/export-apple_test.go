@@ -0,0 +1,93 @@
+package code
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// appleSchemaEnv names the variable holding the path of the Dictionary Development
+// Kit schema, documents/DictionarySchema/AppleDictionarySchema.rng in the kit. Apple's
+// license does not allow vendoring it.
+const appleSchemaEnv = "APPLE_DDK_SCHEMA"
+
+func TestExportApple(t *testing.T) {
+	for _, path := range exportTestApple(t) {
+		entries, err := appleEntryIDs(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if len(entries) < 2 {
+			t.Errorf("%s: %d entries, want the front matter and at least one headword", path, len(entries))
+		}
+		seen := make(map[string]bool)
+		for _, id := range entries {
+			if seen[id] {
+				t.Errorf("%s: entry id %s is not unique", path, id)
+			}
+			seen[id] = true
+		}
+		if !seen[appleFrontMatterID] {
+			t.Errorf("%s: no front matter entry %s", path, appleFrontMatterID)
+		}
+	}
+}
+
+// TestExportAppleSchema validates Dictionary.xml against the schema of the Dictionary
+// Development Kit, which build_dict.sh checks it against. It only runs with
+// APPLE_DDK_SCHEMA set.
+func TestExportAppleSchema(t *testing.T) {
+	schema := os.Getenv(appleSchemaEnv)
+	if schema == "" {
+		t.Skipf("set %s to the kit's AppleDictionarySchema.rng to validate Dictionary.xml", appleSchemaEnv)
+	}
+	validateRelaxNG(t, schema, exportTestApple(t))
+}
+
+// exportTestApple runs the apple export on the fixture and returns the paths of its
+// Dictionary.xml files.
+func exportTestApple(t *testing.T) []string {
+	t.Helper()
+	run := buildTestPipeline(t, 1)
+	if err := CallExportApple(run); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := filepath.Glob(filepath.Join(run.Config.ExportDir("apple"), "*", "Dictionary.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("exported %d sources, want one for each of the language pairs of dictionaries 3, 4 and 7", len(paths))
+	}
+	return paths
+}
+
+// appleEntryIDs parses a Dictionary.xml and returns the ids of its d:entry elements.
+func appleEntryIDs(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	decoder := xml.NewDecoder(f)
+	var ids []string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return ids, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := token.(xml.StartElement); ok && e.Name.Local == "entry" {
+			for _, attr := range e.Attr {
+				if attr.Name.Local == "id" {
+					ids = append(ids, attr.Value)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package code
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf16"
+)
+
+// dslAbbreviations expands the part-of-speech abbreviations of the structured sources.
+// Types found here are written as [p] labels and listed in the abbreviations file.
+var dslAbbreviations = map[string]string{
+	"adj": "adjective", "adv": "adverb", "attr": "attributive", "conj": "conjunction",
+	"det": "determiner", "int": "interjection", "intj": "interjection", "n": "noun",
+	"num": "numeral", "part": "particle", "postp": "postposition", "prep": "preposition",
+	"pron": "pronoun", "v": "verb", "vi": "intransitive verb", "vt": "transitive verb",
+}
+
+// dslEscaper escapes the characters that are markup in DSL text.
+var dslEscaper = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "{", `\{`, "}", `\}`,
+	"~", `\~`, "@", `\@`, "<", `\<`, ">", `\>`, "(", `\(`, ")", `\)`)
+
+// dslEscape escapes text for a DSL headword or article line.
+func dslEscape(text string) string {
+	return dslEscaper.Replace(text)
+}
+
+// CallExportDSL writes every selected dictionary as an ABBYY Lingvo DSL file,
+// exports/dsl/<name>.dsl, encoded as UTF-16LE with a byte order mark as Lingvo expects;
+// GoldenDict reads them too. Every article lists the key in its "1" and its Ӏ spelling.
+// Article lines get [mN] indentation from the margin-left:Nem of their Phase 03 HTML,
+// and bold, italic and colored text become [b], [i] and [c]. Structured (JSON) sources
+// add their examples as [ex] and synonyms and redirects as [ref]; known part-of-speech
+// abbreviations become [p] labels, which ExportOptions.Abbreviations explains in a
+// <name>_abrv.dsl file.
+func CallExportDSL(run *PipelineRun) error {
+	dictionaries, err := selectExportDictionaries(run)
+	if err != nil {
+		return err
+	}
+	distDir := run.Config.ExportDir("dsl")
+	if err := os.MkdirAll(distDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	err = forEachExportDictionary(run, "dsl", dictionaries, func(_ int, d exportDictionary) error {
+		return exportDSLDictionary(run.Config, d, distDir)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("DSL export complete: %d dictionaries in %s\n", len(dictionaries), distDir)
+	return nil
+}
+
+// exportDSLDictionary writes the DSL file, and the abbreviations file if asked for, of one dictionary.
+func exportDSLDictionary(config PipelineConfig, d exportDictionary, distDir string) error {
+	source, err := readExportSource(config, d)
+	if err != nil {
+		return err
+	}
+	articles := make(map[string][]string) // key → article lines
+	abbreviations := make(map[string]bool)
+	if source.Structured {
+		for key, w := range source.Words {
+			articles[key] = wordObjectToDSL(w)
+			if _, ok := dslAbbreviations[dslAbbreviation(w.Type)]; ok {
+				abbreviations[dslAbbreviation(w.Type)] = true
+			}
+		}
+	} else {
+		for key, values := range source.HTML {
+			for _, value := range values {
+				articles[key] = append(articles[key], htmlToDSL(value, 0)...)
+			}
+		}
+	}
+
+	header := func(name string) string {
+		return fmt.Sprintf("#NAME \"%s\"\r\n#INDEX_LANGUAGE \"%s\"\r\n#CONTENTS_LANGUAGE \"%s\"\r\n\r\n",
+			strings.ReplaceAll(name, `"`, "'"), languageName(d.FromLang), languageName(d.ToLang))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(header(fmt.Sprintf("%s [%s→%s]", d.Title, d.FromLang, d.ToLang)))
+	count := 0
+	for _, key := range slices.Sorted(maps.Keys(articles)) {
+		if len(articles[key]) == 0 {
+			continue
+		}
+		sb.WriteString(dslEscape(key) + "\r\n")
+		if display := utils.ConvertCircassian1ToPalochka(key); display != key {
+			sb.WriteString(dslEscape(display) + "\r\n")
+		}
+		for _, line := range articles[key] {
+			sb.WriteString("\t" + line + "\r\n")
+		}
+		sb.WriteString("\r\n")
+		count++
+	}
+
+	path := filepath.Join(distDir, d.Name+".dsl")
+	if err := writeUTF16LE(path, sb.String()); err != nil {
+		return err
+	}
+
+	abbreviationsPath := filepath.Join(distDir, d.Name+"_abrv.dsl")
+	if config.Export.Abbreviations && len(abbreviations) > 0 {
+		sb.Reset()
+		sb.WriteString(header(d.Title + " (abbreviations)"))
+		for _, abbreviation := range slices.Sorted(maps.Keys(abbreviations)) {
+			fmt.Fprintf(&sb, "%s\r\n\t%s\r\n\r\n", dslEscape(abbreviation), dslEscape(dslAbbreviations[abbreviation]))
+		}
+		if err := writeUTF16LE(abbreviationsPath, sb.String()); err != nil {
+			return err
+		}
+	} else if err := os.Remove(abbreviationsPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", abbreviationsPath, err)
+	}
+
+	fmt.Printf("  %s: %d articles\n", filepath.Base(path), count)
+	return nil
+}
+
+// writeUTF16LE writes text to path as UTF-16LE with a byte order mark.
+func writeUTF16LE(path, text string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	w.Write([]byte{0xFF, 0xFE})
+	for _, unit := range utf16.Encode([]rune(text)) {
+		w.Write([]byte{byte(unit), byte(unit >> 8)})
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// dslAbbreviation returns a WordObject.Type as a key of dslAbbreviations, e.g. "adj" for "adj.".
+func dslAbbreviation(wordType string) string {
+	return strings.ToLower(strings.Trim(wordType, " .,;:"))
+}
+
+// wordObjectToDSL returns the article lines of a structured entry: the part of speech,
+// the numbered definitions at [m1] with their lines indented below and examples at
+// [m2], then cognates, the derivation, synonyms and the redirect.
+func wordObjectToDSL(w *modals.WordObject) []string {
+	var lines []string
+	if w.Type != "" {
+		if _, ok := dslAbbreviations[dslAbbreviation(w.Type)]; ok {
+			lines = append(lines, "[m0][p]"+dslEscape(dslAbbreviation(w.Type))+"[/p][/m]")
+		} else {
+			lines = append(lines, "[m0][i]"+dslEscape(w.Type)+"[/i][/m]")
+		}
+	}
+
+	for i, def := range w.Definitions {
+		meaning := htmlToDSL(meaningToHTML(def.Meaning), 1)
+		number := fmt.Sprintf("[b]%d.[/b] ", i+1)
+		if len(meaning) == 0 {
+			meaning = []string{"[m1][/m]"}
+		}
+		meaning[0] = strings.Replace(meaning[0], "]", "]"+number, 1)
+		lines = append(lines, meaning...)
+
+		for _, ex := range def.Examples {
+			example := dslBoldMarkers(ex.Sentence)
+			if ex.Translation != "" {
+				example += " — " + dslBoldMarkers(ex.Translation)
+			}
+			lines = append(lines, "[m2][ex]"+example+"[/ex][/m]")
+		}
+	}
+
+	if len(w.Cognates) > 0 {
+		cognates := make([]string, len(w.Cognates))
+		for i, c := range w.Cognates {
+			cognates[i] = "[i]" + dslEscape(c.Dialect) + "[/i] " + dslEscape(c.Word)
+		}
+		lines = append(lines, "[m1]"+strings.Join(cognates, "; ")+"[/m]")
+	}
+	if w.Derivation != "" {
+		lines = append(lines, "[m1][i]"+dslEscape(w.Derivation)+"[/i][/m]")
+	}
+	if len(w.Synonyms) > 0 {
+		synonyms := make([]string, len(w.Synonyms))
+		for i, synonym := range w.Synonyms {
+			// AddSynonym stores "word (explanation)"; only the word is a link
+			word, explanation, found := strings.Cut(synonym, " (")
+			synonyms[i] = "[ref]" + dslEscape(word) + "[/ref]"
+			if found {
+				synonyms[i] += " " + dslEscape("("+explanation)
+			}
+		}
+		lines = append(lines, "[m1]Syn.: "+strings.Join(synonyms, ", ")+"[/m]")
+	}
+	if w.Redirect != "" {
+		lines = append(lines, "[m1]→ [ref]"+dslEscape(w.Redirect)+"[/ref][/m]")
+	}
+	return lines
+}
+
+// dslBoldMarkers escapes text and turns its |bold| markers into [b].
+func dslBoldMarkers(text string) string {
+	return pipeMarkerRegex.ReplaceAllString(dslEscape(text), "[b]$1[/b]")
+}
+
+// htmlToDSL converts an HTML fragment to DSL article lines, one per block (div, p,
+// heading, list item, br). A line is indented by [mN], N being indent plus the total
+// margin-left in em of its blocks. Bold, italic, underlined, sub- and superscript text
+// and font colors become DSL tags; other markup is dropped and its text escaped.
+func htmlToDSL(fragment string, indent int) []string {
+	decoder := xml.NewDecoder(strings.NewReader("<root>" + utils.HTMLToXHTML(fragment) + "</root>"))
+	var lines []string
+	var current strings.Builder
+	var closers []string     // closing tags of the open elements, "" when nothing was emitted
+	margins := []int{indent} // indentation of the open blocks
+
+	// A block only starts a new line when no formatting is open around it
+	flush := func() {
+		if slices.ContainsFunc(closers, func(c string) bool { return c != "" }) {
+			current.WriteString(" ")
+			return
+		}
+		if text := strings.Join(strings.Fields(current.String()), " "); text != "" {
+			lines = append(lines, fmt.Sprintf("[m%d]%s[/m]", min(margins[len(margins)-1], 9), text))
+		}
+		current.Reset()
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break // io.EOF; HTMLToXHTML output is well-formed
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "root" {
+				continue
+			}
+			if utils.HTMLBlockElements[t.Name.Local] {
+				flush()
+				margins = append(margins, margins[len(margins)-1]+dslMargin(t))
+			}
+			open, closer := dslFormatting(t)
+			current.WriteString(open)
+			closers = append(closers, closer)
+		case xml.EndElement:
+			if t.Name.Local == "root" {
+				continue
+			}
+			current.WriteString(closers[len(closers)-1])
+			closers = closers[:len(closers)-1]
+			if utils.HTMLBlockElements[t.Name.Local] {
+				flush()
+				margins = margins[:len(margins)-1]
+			}
+		case xml.CharData:
+			current.WriteString(dslEscape(string(t)))
+		}
+	}
+	flush()
+	return lines
+}
+
+// dslMargin returns the margin-left of an element in whole em.
+func dslMargin(e xml.StartElement) int {
+	for _, attr := range e.Attr {
+		if attr.Name.Local != "style" {
+			continue
+		}
+		for _, declaration := range strings.Split(attr.Value, ";") {
+			property, value, _ := strings.Cut(declaration, ":")
+			if strings.TrimSpace(property) == "margin-left" {
+				return int(math.Round(parseEm(strings.TrimSpace(value))))
+			}
+		}
+	}
+	return 0
+}
+
+// dslFormatting returns the DSL tags that render an XHTML element, if any.
+func dslFormatting(e xml.StartElement) (open, closer string) {
+	wrap := func(tag, attr string) {
+		open += "[" + tag + attr + "]"
+		closer = "[/" + tag + "]" + closer
+	}
+	switch e.Name.Local {
+	case "b", "strong", "h1", "h2", "h3", "h4", "h5", "h6":
+		wrap("b", "")
+	case "i", "em":
+		wrap("i", "")
+	case "u":
+		wrap("u", "")
+	case "sub":
+		wrap("sub", "")
+	case "sup":
+		wrap("sup", "")
+	}
+	for _, attr := range e.Attr {
+		switch attr.Name.Local {
+		case "color":
+			wrap("c", " "+strings.Map(func(r rune) rune {
+				if r == '[' || r == ']' || r == ' ' {
+					return -1
+				}
+				return r
+			}, attr.Value))
+		case "style":
+			for _, declaration := range strings.Split(attr.Value, ";") {
+				property, value, _ := strings.Cut(declaration, ":")
+				value = strings.TrimSpace(value)
+				switch strings.TrimSpace(property) {
+				case "font-weight":
+					if value == "bold" {
+						wrap("b", "")
+					}
+				case "font-style":
+					if value == "italic" {
+						wrap("i", "")
+					}
+				}
+			}
+		}
+	}
+	return open, closer
+}
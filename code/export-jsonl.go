@@ -0,0 +1,137 @@
+package code
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// JSONLinesExportFileName is the file written by the jsonl export, without the .gz
+// that ExportOptions.Gzip adds.
+const JSONLinesExportFileName = "entries.jsonl"
+
+// JSONLinesSchemaFileName is the JSON Schema of the lines of JSONLinesExportFileName.
+const JSONLinesSchemaFileName = "entries.schema.json"
+
+// CallExportJSONLines writes one modals.EntryRecord per headword and dictionary of the
+// selected dictionaries to exports/jsonl/entries.jsonl, gzip-compressed to
+// entries.jsonl.gz with ExportOptions.Gzip, for data analysis without the nested
+// Phase 04 format. Records follow the word order of Phase 04 and, within a word, the
+// dictionary rank. Dictionaries in the json format add their Phase 02 WordObject.
+//
+// entries.schema.json, the JSON Schema of a record, is generated from the Go type by
+// utils.JSONSchema, so it cannot drift from the data.
+func CallExportJSONLines(run *PipelineRun) error {
+	dictionaries, err := selectExportDictionaries(run)
+	if err != nil {
+		return err
+	}
+	distDir := run.Config.ExportDir("jsonl")
+	if err := os.MkdirAll(distDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	selected := make(map[int]exportDictionary, len(dictionaries))
+	structured := make(map[int]map[string]*modals.WordObject)
+	for _, d := range dictionaries {
+		selected[d.Id] = d
+		if format, _ := modals.ParseDictFormat(d.Desc.Format); format != modals.DictFormatJSON {
+			continue
+		}
+		source, err := readExportSource(run.Config, d)
+		if err != nil {
+			return err
+		}
+		structured[d.Id] = source.Words
+	}
+
+	// Only one of the compressed and the plain file is current; remove the other
+	path := filepath.Join(distDir, JSONLinesExportFileName)
+	stale := path + ".gz"
+	if run.Config.Export.Gzip {
+		path, stale = stale, path
+	}
+	if err := os.Remove(stale); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", stale, err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+	bw := bufio.NewWriter(f)
+	var w io.Writer = bw
+	var gz *gzip.Writer
+	if run.Config.Export.Gzip {
+		gz = gzip.NewWriter(bw) // no name or time in the header, so rebuilds are identical
+		w = gz
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	count := 0
+	counts := make(map[int]int, len(dictionaries))
+	err = readExportWords(run.Config, func(merged modals.MergedWord) error {
+		for _, entry := range merged.Entries {
+			d, ok := selected[entry.Id]
+			if !ok {
+				continue
+			}
+			record := modals.EntryRecord{
+				Word:         merged.Word,
+				DisplayWord:  utils.ConvertCircassian1ToPalochka(merged.Word),
+				DictionaryId: d.Id,
+				Dictionary:   d.Title,
+				FromLang:     d.FromLang,
+				ToLang:       d.ToLang,
+				Format:       d.Desc.Format,
+				Text:         strings.Join(utils.HTMLToTextLines(entry.Html), "\n"),
+				Html:         entry.Html,
+				Structured:   structured[d.Id][merged.Word],
+			}
+			if err := enc.Encode(record); err != nil {
+				return fmt.Errorf("failed to write %s: %w", path, err)
+			}
+			counts[d.Id]++
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	schemaPath := filepath.Join(distDir, JSONLinesSchemaFileName)
+	schema := utils.JSONSchema(modals.EntryRecord{}, "Circassian dictionary entry")
+	schema["description"] = fmt.Sprintf("One line of %s: the entry of one dictionary for one headword.", JSONLinesExportFileName)
+	if err := utils.SaveDictToJSON(schemaPath, schema); err != nil {
+		return fmt.Errorf("failed to write %s: %w", schemaPath, err)
+	}
+
+	for _, d := range dictionaries {
+		fmt.Printf("  %s: %d records\n", d.Name, counts[d.Id])
+		run.Summary.Succeeded(d.Desc, "jsonl")
+	}
+	fmt.Printf("JSON Lines export complete: %d records in %s\n", count, path)
+	return nil
+}
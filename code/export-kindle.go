@@ -0,0 +1,238 @@
+package code
+
+import (
+	"bufio"
+	"fmt"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// kindleMaxContentBytes is the size after which a Kindle book starts a new XHTML
+// file; kindlegen handles many small files better than one large one.
+const kindleMaxContentBytes = 256 * 1024
+
+// kindleXHTMLHeader opens a content file. The idx and mbp namespaces are those of
+// the Amazon Kindle Publishing Guidelines.
+const kindleXHTMLHeader = `<?xml version="1.0" encoding="utf-8"?>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:mbp="https://kindlegen.s3.amazonaws.com/AmazonKindlePublishingGuidelines.pdf" xmlns:idx="https://kindlegen.s3.amazonaws.com/AmazonKindlePublishingGuidelines.pdf">
+<head><meta http-equiv="Content-Type" content="text/html; charset=utf-8"/></head>
+<body>
+<mbp:frameset>
+`
+
+const kindleXHTMLFooter = `</mbp:frameset>
+</body>
+</html>
+`
+
+// CallExportKindle writes a Kindle dictionary source per language pair of the
+// selected dictionaries to exports/kindle/<From>-<To>/: an OPF package whose
+// DictionaryInLanguage/DictionaryOutLanguage come from the pair, and XHTML content
+// files with one idx:entry per headword. Build the .mobi/.azw3 with kindlegen or
+// Kindle Previewer from the OPF file.
+//
+// The idx:orth value is the display spelling with Ӏ; the "1" spelling and the
+// inflected forms listed by sources whose converter is a FormsExtractor are added
+// as idx:iform so lookups of either spelling or any form find the entry.
+func CallExportKindle(run *PipelineRun) error {
+	dictionaries, err := selectExportDictionaries(run)
+	if err != nil {
+		return err
+	}
+	distDir := run.Config.ExportDir("kindle")
+
+	var books []*kindleBook
+	bookOf := make(map[string]*kindleBook)
+	for _, d := range dictionaries {
+		pair := d.Desc.LanguagePair()
+		book, ok := bookOf[pair]
+		if !ok {
+			book = &kindleBook{pair: pair, fromLang: d.FromLang, toLang: d.ToLang, titles: make(map[int]string)}
+			bookOf[pair] = book
+			books = append(books, book)
+		}
+		book.dictionaries = append(book.dictionaries, d)
+		book.titles[d.Id] = d.Title
+	}
+
+	forms, err := loadForms(run.Config, dictionaries)
+	if err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		if err := book.create(distDir); err != nil {
+			return err
+		}
+		defer book.close() // no-op once finished
+	}
+
+	err = readExportWords(run.Config, func(merged modals.MergedWord) error {
+		for _, book := range books {
+			article := combinedArticle(merged.Entries, book.titles)
+			if article == "" {
+				continue
+			}
+			if err := book.add(merged.Word, article, forms[merged.Word]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		if err := book.finish(); err != nil {
+			return err
+		}
+		for _, d := range book.dictionaries {
+			run.Summary.Succeeded(d.Desc, "kindle")
+		}
+	}
+
+	fmt.Printf("Kindle export complete: %d books in %s\n", len(books), distDir)
+	return nil
+}
+
+// kindleBook is the Kindle dictionary of one language pair.
+type kindleBook struct {
+	pair             string
+	fromLang, toLang string
+	dictionaries     []exportDictionary
+	titles           map[int]string
+
+	dir          string
+	name         string
+	contentFiles []string
+	file         *os.File
+	w            *bufio.Writer
+	written      int // bytes written to the current content file
+	entries      int
+}
+
+// create empties the book's folder.
+func (b *kindleBook) create(distDir string) error {
+	b.name = languagePairFileName(b.pair)
+	b.dir = filepath.Join(distDir, b.name)
+	if err := os.RemoveAll(b.dir); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", b.dir, err)
+	}
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	return nil
+}
+
+// add writes the idx:entry of one headword, starting a new content file when the
+// current one is full.
+func (b *kindleBook) add(word, article string, forms []string) error {
+	if b.file == nil || b.written >= kindleMaxContentBytes {
+		if err := b.close(); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("content-%03d.xhtml", len(b.contentFiles)+1)
+		f, err := os.Create(filepath.Join(b.dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", name, err)
+		}
+		b.file, b.w, b.written = f, bufio.NewWriter(f), 0
+		b.contentFiles = append(b.contentFiles, name)
+		b.w.WriteString(kindleXHTMLHeader)
+	}
+
+	display := utils.ConvertCircassian1ToPalochka(word)
+	var inflections []string
+	for _, spelling := range append([]string{word}, forms...) {
+		for _, variant := range []string{spelling, utils.ConvertCircassian1ToPalochka(spelling)} {
+			if variant != display && !slices.Contains(inflections, variant) {
+				inflections = append(inflections, variant)
+			}
+		}
+	}
+
+	var entry strings.Builder
+	entry.WriteString(`<idx:entry name="default" scriptable="yes" spell="yes">` + "\n")
+	fmt.Fprintf(&entry, `<idx:orth value="%s"><b>%s</b>`, utils.EscapeXML(display), utils.EscapeXML(display))
+	if len(inflections) > 0 {
+		entry.WriteString("\n<idx:infl>")
+		for _, inflection := range inflections {
+			fmt.Fprintf(&entry, `<idx:iform value="%s"/>`, utils.EscapeXML(inflection))
+		}
+		entry.WriteString("</idx:infl>\n")
+	}
+	entry.WriteString("</idx:orth>\n")
+	entry.WriteString(utils.HTMLToXHTML(article))
+	entry.WriteString("\n</idx:entry>\n<hr/>\n")
+
+	n, err := b.w.WriteString(entry.String())
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", b.file.Name(), err)
+	}
+	b.written += n
+	b.entries++
+	return nil
+}
+
+// close completes the current content file, if any.
+func (b *kindleBook) close() error {
+	if b.file == nil {
+		return nil
+	}
+	b.w.WriteString(kindleXHTMLFooter)
+	err := b.w.Flush()
+	if closeErr := b.file.Close(); err == nil {
+		err = closeErr
+	}
+	b.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to write content file: %w", err)
+	}
+	return nil
+}
+
+// finish closes the last content file and writes the OPF package.
+func (b *kindleBook) finish() error {
+	if err := b.close(); err != nil {
+		return err
+	}
+
+	titles := make([]string, len(b.dictionaries))
+	for i, d := range b.dictionaries {
+		titles[i] = d.Title
+	}
+
+	var opf strings.Builder
+	opf.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	opf.WriteString(`<package version="2.0" xmlns="http://www.idpf.org/2007/opf" unique-identifier="uid">` + "\n")
+	opf.WriteString("<metadata>\n")
+	opf.WriteString(`<dc-metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	fmt.Fprintf(&opf, "<dc:Identifier id=\"uid\">learn-circassian-%s</dc:Identifier>\n", utils.EscapeXML(strings.ToLower(b.name)))
+	fmt.Fprintf(&opf, "<dc:Title>Circassian dictionaries %s → %s</dc:Title>\n", utils.EscapeXML(b.fromLang), utils.EscapeXML(b.toLang))
+	fmt.Fprintf(&opf, "<dc:Language>%s</dc:Language>\n", languageTag(b.fromLang))
+	fmt.Fprintf(&opf, "<dc:Description>%s</dc:Description>\n", utils.EscapeXML(strings.Join(titles, "; ")))
+	opf.WriteString("</dc-metadata>\n<x-metadata>\n")
+	fmt.Fprintf(&opf, "<DictionaryInLanguage>%s</DictionaryInLanguage>\n", languageTag(b.fromLang))
+	fmt.Fprintf(&opf, "<DictionaryOutLanguage>%s</DictionaryOutLanguage>\n", languageTag(b.toLang))
+	opf.WriteString("<DefaultLookupIndex>default</DefaultLookupIndex>\n</x-metadata>\n</metadata>\n<manifest>\n")
+	for i, name := range b.contentFiles {
+		fmt.Fprintf(&opf, "<item id=\"content-%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, name)
+	}
+	opf.WriteString("</manifest>\n<spine>\n")
+	for i := range b.contentFiles {
+		fmt.Fprintf(&opf, "<itemref idref=\"content-%d\"/>\n", i+1)
+	}
+	opf.WriteString("</spine>\n</package>\n")
+
+	opfPath := filepath.Join(b.dir, b.name+".opf")
+	if err := os.WriteFile(opfPath, []byte(opf.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", opfPath, err)
+	}
+	fmt.Printf("  %s: %d entries in %d content files\n", b.name, b.entries, len(b.contentFiles))
+	return nil
+}
@@ -0,0 +1,360 @@
+package code
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// siteShardRunes is the length of the search prefix that names a search index shard.
+// Queries shorter than it are sent to the letter index instead.
+const siteShardRunes = 2
+
+// siteOtherLetter names the index of headwords that do not start with a letter.
+const siteOtherLetter = "other"
+
+// siteCSS is assets/style.css.
+const siteCSS = `body { font-family: system-ui, sans-serif; max-width: 50em; margin: 0 auto; padding: 0 1em 2em; line-height: 1.5; }
+header { display: flex; flex-wrap: wrap; gap: 1em; align-items: center; padding: 1em 0; border-bottom: 1px solid #ddd; }
+header a.home { font-weight: bold; text-decoration: none; }
+header input { flex: 1; min-width: 12em; padding: .3em .5em; font-size: 1em; }
+#results { list-style: none; padding: 0; margin: 0; }
+#results li { padding: .2em 0; }
+nav.letters a { display: inline-block; min-width: 1.5em; margin: .1em; text-align: center; }
+ul.words { columns: 14em; padding-left: 1em; }
+section.entry { margin: 1.5em 0; }
+section.entry h2 { font-size: 1em; color: #555; border-bottom: 1px solid #eee; }
+`
+
+// siteSearchJS is assets/search.js, SHARD_RUNES standing for siteShardRunes. It
+// normalizes the query like utils.NormalizePhrase, loads the shard named by the hex
+// UTF-8 of its first characters and lists the headwords starting with it.
+const siteSearchJS = `(function () {
+  var root = document.currentScript.getAttribute("data-root");
+  var sticks = "IilıİӀӏІі";
+  var cyrillicThenStick = new RegExp("(\\p{Script=Cyrillic})[" + sticks + "]", "gu");
+  var stickThenCyrillic = new RegExp("[" + sticks + "](\\p{Script=Cyrillic})", "gu");
+  var shards = {};
+
+  function normalize(s) {
+    s = s.toLowerCase().replace(cyrillicThenStick, "$11").replace(stickThenCyrillic, "1$1");
+    return s.split(/[^\p{L}\p{N}-]+/u).map(function (w) { return w.replace(/^-+|-+$/g, ""); })
+      .filter(Boolean).join(" ");
+  }
+
+  function hex(s) {
+    return Array.from(new TextEncoder().encode(s), function (b) { return b.toString(16).padStart(2, "0"); }).join("");
+  }
+
+  function show(items) {
+    var list = document.getElementById("results");
+    list.innerHTML = "";
+    items.forEach(function (item) {
+      var li = document.createElement("li"), a = document.createElement("a");
+      a.href = root + item[2];
+      a.textContent = item[1];
+      li.appendChild(a);
+      list.appendChild(li);
+    });
+  }
+
+  function search(query) {
+    var q = normalize(query), chars = Array.from(q);
+    if (chars.length === 0) { show([]); return; }
+    if (chars.length < SHARD_RUNES) {
+      var letter = chars[0] === "1" ? "ӏ" : /\p{L}/u.test(chars[0]) ? chars[0] : "other";
+      show([[q, "Words starting with " + letter.toUpperCase() + " …", "letters/" + encodeURIComponent(letter) + ".html"]]);
+      return;
+    }
+    var name = hex(chars.slice(0, SHARD_RUNES).join(""));
+    if (!shards[name]) {
+      shards[name] = fetch(root + "search/" + name + ".json")
+        .then(function (r) { return r.ok ? r.json() : []; })
+        .catch(function () { return []; });
+    }
+    shards[name].then(function (entries) {
+      if (normalize(document.getElementById("q").value) !== q) return;
+      show(entries.filter(function (e) { return e[0].startsWith(q); }).slice(0, 50));
+    });
+  }
+
+  var input = document.getElementById("q");
+  input.addEventListener("input", function () { search(input.value); });
+  var initial = new URLSearchParams(location.search).get("q");
+  if (initial) { input.value = initial; search(initial); }
+})();
+`
+
+// CallExportSite renders the Phase 04 data of the selected dictionaries as a static
+// website in exports/site/, to host on any static file host:
+//   - index.html: the search box, the letter indexes and the dictionaries
+//   - words/<letter>/<word>.html: one page per headword with the entries of every
+//     dictionary under its title
+//   - letters/<letter>.html: the headwords starting with a letter
+//   - dictionaries/<id>.html: a dictionary's languages and headwords
+//   - search/<hex>.json: the search index, sharded by the first siteShardRunes
+//     characters of the search form, as [search form, headword, page] rows
+//
+// The search form is that of utils.NormalizePhrase, which search.js repeats, so both
+// palochka spellings find a headword.
+func CallExportSite(run *PipelineRun) error {
+	dictionaries, err := selectExportDictionaries(run)
+	if err != nil {
+		return err
+	}
+	distDir := run.Config.ExportDir("site")
+	if err := os.RemoveAll(distDir); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", distDir, err)
+	}
+
+	titles := make(map[int]string, len(dictionaries))
+	for _, d := range dictionaries {
+		titles[d.Id] = d.Title
+	}
+
+	letters := make(map[string][]siteLink)      // letter → headwords
+	dictionaryWords := make(map[int][]siteLink) // dictionary id → headwords
+	shards := make(map[string][][3]string)      // shard name → search rows
+	count := 0
+
+	err = readExportWords(run.Config, func(merged modals.MergedWord) error {
+		var body strings.Builder
+		display := utils.ConvertCircassian1ToPalochka(merged.Word)
+		letter := siteLetter(display)
+		page := "words/" + letter + "/" + siteSlug(merged.Word) + ".html"
+		link := siteLink{word: display, href: page}
+
+		fmt.Fprintf(&body, "<h1>%s</h1>\n", html.EscapeString(display))
+		selected := false
+		for _, entry := range merged.Entries {
+			title, ok := titles[entry.Id]
+			if !ok {
+				continue
+			}
+			selected = true
+			fmt.Fprintf(&body, "<section class=\"entry\"><h2><a href=\"../../dictionaries/%d.html\">%s</a></h2>\n%s\n</section>\n",
+				entry.Id, html.EscapeString(title), entry.Html)
+			dictionaryWords[entry.Id] = append(dictionaryWords[entry.Id], link)
+		}
+		if !selected {
+			return nil
+		}
+
+		if err := writeSitePage(distDir, page, display, body.String()); err != nil {
+			return err
+		}
+		letters[letter] = append(letters[letter], link)
+		if search := utils.NormalizePhrase(merged.Word); utf8.RuneCountInString(search) >= siteShardRunes {
+			shard := siteShardName(search)
+			shards[shard] = append(shards[shard], [3]string{search, display, siteHref(page)})
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	letterNames := slices.SortedFunc(maps.Keys(letters), func(a, b string) int {
+		// Headwords that do not start with a letter come last
+		if (a == siteOtherLetter) != (b == siteOtherLetter) {
+			if a == siteOtherLetter {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a, b)
+	})
+	var nav strings.Builder
+	nav.WriteString("<nav class=\"letters\">")
+	for _, letter := range letterNames {
+		fmt.Fprintf(&nav, "<a href=\"%s\">%s</a> ", siteHref("letters/"+letter+".html"), html.EscapeString(siteLetterLabel(letter)))
+	}
+	nav.WriteString("</nav>\n")
+
+	byWord := func(a, b siteLink) int { return strings.Compare(a.word, b.word) }
+	for _, letter := range letterNames {
+		slices.SortStableFunc(letters[letter], byWord)
+		body := fmt.Sprintf("<h1>%s</h1>\n%s", html.EscapeString(siteLetterLabel(letter)), siteWordList(letters[letter], "../"))
+		if err := writeSitePage(distDir, "letters/"+letter+".html", siteLetterLabel(letter), body); err != nil {
+			return err
+		}
+	}
+
+	var dictionaryList strings.Builder
+	dictionaryList.WriteString("<ul>\n")
+	for _, d := range dictionaries {
+		words := dictionaryWords[d.Id]
+		fmt.Fprintf(&dictionaryList, "<li><a href=\"dictionaries/%d.html\">%s</a> (%s → %s, %d headwords)</li>\n",
+			d.Id, html.EscapeString(d.Title), html.EscapeString(languageName(d.FromLang)), html.EscapeString(languageName(d.ToLang)), len(words))
+
+		slices.SortStableFunc(words, byWord)
+		body := fmt.Sprintf("<h1>%s</h1>\n<p>%s → %s, %d headwords. Converted from %s.</p>\n%s",
+			html.EscapeString(d.Title), html.EscapeString(languageName(d.FromLang)), html.EscapeString(languageName(d.ToLang)),
+			len(words), html.EscapeString(d.Desc.FileName), siteWordList(words, "../"))
+		if err := writeSitePage(distDir, fmt.Sprintf("dictionaries/%d.html", d.Id), d.Title, body); err != nil {
+			return err
+		}
+	}
+	dictionaryList.WriteString("</ul>\n")
+
+	index := fmt.Sprintf("<h1>Circassian dictionaries</h1>\n<ul id=\"results\"></ul>\n<h2>Browse</h2>\n%s<h2>Dictionaries</h2>\n%s",
+		nav.String(), dictionaryList.String())
+	if err := writeSitePage(distDir, "index.html", "Circassian dictionaries", index); err != nil {
+		return err
+	}
+
+	for name, rows := range shards {
+		data, err := json.Marshal(rows)
+		if err != nil {
+			return fmt.Errorf("failed to encode search shard %s: %w", name, err)
+		}
+		if err := writeSiteFile(distDir, "search/"+name+".json", data); err != nil {
+			return err
+		}
+	}
+	if err := writeSiteFile(distDir, "assets/style.css", []byte(siteCSS)); err != nil {
+		return err
+	}
+	if err := writeSiteFile(distDir, "assets/search.js", []byte(strings.ReplaceAll(siteSearchJS, "SHARD_RUNES", strconv.Itoa(siteShardRunes)))); err != nil {
+		return err
+	}
+
+	for _, d := range dictionaries {
+		run.Summary.Succeeded(d.Desc, "site")
+	}
+	fmt.Printf("Site export complete: %d headword pages, %d letters, %d search shards in %s\n", count, len(letters), len(shards), distDir)
+	return nil
+}
+
+// siteLink is a headword and the path of its page from the site root.
+type siteLink struct {
+	word string
+	href string
+}
+
+// siteLetter returns the index letter of a headword: its first letter lowercased, or
+// siteOtherLetter.
+func siteLetter(word string) string {
+	r, _ := utf8.DecodeRuneInString(word)
+	if !unicode.IsLetter(r) {
+		return siteOtherLetter
+	}
+	return string(unicode.ToLower(r))
+}
+
+// siteLetterLabel returns the heading of a letter index, e.g. "Ӏ" for "ӏ". Letters
+// whose capital is another letter's, like the Turkish dotless ı, keep their case.
+func siteLetterLabel(letter string) string {
+	if letter == siteOtherLetter {
+		return "#"
+	}
+	if upper := strings.ToUpper(letter); strings.ToLower(upper) == letter {
+		return upper
+	}
+	return letter
+}
+
+// siteSlug returns the file name of a headword's page: the headword with characters
+// other than letters, digits and hyphens replaced by "_". When that changed it, or it
+// has capitals that would clash on case-insensitive file systems, the start of the
+// headword's SHA-256 is appended so every headword gets its own page.
+func siteSlug(word string) string {
+	slug := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			return r
+		}
+		return '_'
+	}, word)
+	if slug != word || strings.ToLower(word) != word {
+		sum := sha256.Sum256([]byte(word))
+		slug += "-" + hex.EncodeToString(sum[:4])
+	}
+	return slug
+}
+
+// siteShardName returns the name of the search shard of a search form.
+func siteShardName(search string) string {
+	prefix := search
+	for i := range search {
+		if utf8.RuneCountInString(search[:i]) == siteShardRunes {
+			prefix = search[:i]
+			break
+		}
+	}
+	return hex.EncodeToString([]byte(prefix))
+}
+
+// siteHref percent-escapes every segment of a path from the site root.
+func siteHref(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// siteWordList returns the list of links to headword pages, relative to a page at root.
+func siteWordList(links []siteLink, root string) string {
+	var sb strings.Builder
+	sb.WriteString("<ul class=\"words\">\n")
+	for _, link := range links {
+		fmt.Fprintf(&sb, "<li><a href=\"%s%s\">%s</a></li>\n", root, siteHref(link.href), html.EscapeString(link.word))
+	}
+	sb.WriteString("</ul>\n")
+	return sb.String()
+}
+
+// writeSitePage writes a page of the site, path being relative to its root.
+func writeSitePage(distDir, path, title, body string) error {
+	root := strings.Repeat("../", strings.Count(path, "/"))
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<link rel="stylesheet" href="%sassets/style.css">
+</head>
+<body>
+<header><a class="home" href="%sindex.html">Circassian dictionaries</a><input id="q" type="search" placeholder="Search" autocomplete="off"></header>
+%s%s<script src="%sassets/search.js" data-root="%s"></script>
+</body>
+</html>
+`, html.EscapeString(title), root, root, siteResults(path), body, root, root)
+	return writeSiteFile(distDir, path, []byte(page))
+}
+
+// siteResults returns the search result list of pages other than the index, which
+// has its own below the heading.
+func siteResults(path string) string {
+	if path == "index.html" {
+		return ""
+	}
+	return "<ul id=\"results\"></ul>\n"
+}
+
+// writeSiteFile writes a file of the site, creating its folder.
+func writeSiteFile(distDir, path string, data []byte) error {
+	fullPath := filepath.Join(distDir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", fullPath, err)
+	}
+	return nil
+}
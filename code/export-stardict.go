@@ -0,0 +1,265 @@
+package code
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// starDictMaxWordBytes is the StarDict limit on the length of an index word.
+const starDictMaxWordBytes = 255
+
+// combinedExportName is the file name stem of a combined export.
+const combinedExportName = "circassian-combined"
+
+// CallExportStarDict writes the merged database as StarDict dictionaries for
+// GoldenDict, KOReader and other StarDict readers: a folder per selected dictionary,
+// or a single combined one with ExportOptions.Combined. Each folder holds
+// <name>.ifo, <name>.idx, <name>.dict.dz and <name>.syn. Definitions are the Phase 04
+// HTML (sametypesequence=h). Keys keep the "1" spelling of palochka; the .syn file
+// adds their display spelling with Ӏ so both can be looked up.
+func CallExportStarDict(run *PipelineRun) error {
+	dictionaries, err := selectExportDictionaries(run)
+	if err != nil {
+		return err
+	}
+	distDir := run.Config.ExportDir("stardict")
+
+	var builders []*starDictBuilder
+	if run.Config.Export.Combined {
+		description := "Combined Circassian dictionaries:"
+		for _, d := range dictionaries {
+			description += "<br>" + html.EscapeString(d.Title)
+		}
+		builders = []*starDictBuilder{{name: combinedExportName, bookname: "Circassian dictionaries", description: description}}
+	} else {
+		for _, d := range dictionaries {
+			builders = append(builders, &starDictBuilder{
+				name:        d.Name,
+				bookname:    fmt.Sprintf("%s [%s→%s]", d.Title, d.FromLang, d.ToLang),
+				description: html.EscapeString(fmt.Sprintf("%s, %s to %s. Converted from %s.", d.Title, d.FromLang, d.ToLang, d.Desc.FileName)),
+			})
+		}
+	}
+
+	for _, b := range builders {
+		if err := b.create(distDir); err != nil {
+			return err
+		}
+		defer b.dict.Close() // no-op once finished
+	}
+
+	builderOf := make(map[int]*starDictBuilder, len(dictionaries))
+	titles := make(map[int]string, len(dictionaries))
+	for i, d := range dictionaries {
+		if !run.Config.Export.Combined {
+			builderOf[d.Id] = builders[i]
+		}
+		titles[d.Id] = d.Title
+	}
+
+	err = readExportWords(run.Config, func(merged modals.MergedWord) error {
+		if !run.Config.Export.Combined {
+			for _, entry := range merged.Entries {
+				if b, ok := builderOf[entry.Id]; ok {
+					if err := b.add(merged.Word, entry.Html); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+
+		// One article per word, with a heading for each source dictionary
+		article := combinedArticle(merged.Entries, titles)
+		if article == "" {
+			return nil
+		}
+		return builders[0].add(merged.Word, article)
+	})
+	if err != nil {
+		return err
+	}
+
+	if run.Config.Export.Combined {
+		if err := builders[0].finish(); err != nil {
+			return err
+		}
+		for _, d := range dictionaries {
+			run.Summary.Succeeded(d.Desc, "stardict")
+		}
+	} else {
+		err = forEachExportDictionary(run, "stardict", dictionaries, func(i int, _ exportDictionary) error {
+			return builders[i].finish()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("StarDict export complete: %d dictionaries in %s\n", len(builders), distDir)
+	return nil
+}
+
+// starDictWord is one .idx record: a key and the location of its article in the .dict file.
+type starDictWord struct {
+	word   string
+	offset uint32
+	size   uint32
+}
+
+// starDictBuilder collects the articles of one StarDict dictionary. Articles are
+// written to the .dict file as they arrive; the index is kept in memory and sorted
+// when the dictionary is finished.
+type starDictBuilder struct {
+	name        string
+	bookname    string
+	description string
+
+	dir     string
+	dict    *os.File
+	w       *bufio.Writer
+	offset  uint64
+	words   []starDictWord
+	skipped int // keys longer than starDictMaxWordBytes
+}
+
+func (b *starDictBuilder) path(ext string) string {
+	return filepath.Join(b.dir, b.name+ext)
+}
+
+// create empties the dictionary's folder and opens its .dict file.
+func (b *starDictBuilder) create(distDir string) error {
+	b.dir = filepath.Join(distDir, b.name)
+	if err := os.RemoveAll(b.dir); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", b.dir, err)
+	}
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	f, err := os.Create(b.path(".dict"))
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", b.path(".dict"), err)
+	}
+	b.dict, b.w = f, bufio.NewWriter(f)
+	return nil
+}
+
+// add appends an article for word.
+func (b *starDictBuilder) add(word, article string) error {
+	if len(word) > starDictMaxWordBytes {
+		b.skipped++
+		return nil
+	}
+	if b.offset+uint64(len(article)) > math.MaxUint32 {
+		return fmt.Errorf("%s is larger than the 4 GiB StarDict limit", b.path(".dict"))
+	}
+	if _, err := b.w.WriteString(article); err != nil {
+		return fmt.Errorf("failed to write %s: %w", b.path(".dict"), err)
+	}
+	b.words = append(b.words, starDictWord{word: word, offset: uint32(b.offset), size: uint32(len(article))})
+	b.offset += uint64(len(article))
+	return nil
+}
+
+// finish writes the .idx, .syn and .ifo files and compresses the .dict file to .dict.dz.
+func (b *starDictBuilder) finish() error {
+	if err := b.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", b.path(".dict"), err)
+	}
+	if err := b.dict.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", b.path(".dict"), err)
+	}
+	if b.skipped > 0 {
+		fmt.Printf("  %s: skipped %d keys longer than %d bytes\n", b.name, b.skipped, starDictMaxWordBytes)
+	}
+
+	slices.SortFunc(b.words, func(x, y starDictWord) int { return starDictCompare(x.word, y.word) })
+	var idx bytes.Buffer
+	for _, w := range b.words {
+		idx.WriteString(w.word)
+		idx.WriteByte(0)
+		binary.Write(&idx, binary.BigEndian, w.offset)
+		binary.Write(&idx, binary.BigEndian, w.size)
+	}
+	if err := os.WriteFile(b.path(".idx"), idx.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", b.path(".idx"), err)
+	}
+
+	// Synonyms point at the position of their key in the sorted index
+	type synonym struct {
+		word  string
+		index uint32
+	}
+	var synonyms []synonym
+	for i, w := range b.words {
+		display := utils.ConvertCircassian1ToPalochka(w.word)
+		if display != w.word && len(display) <= starDictMaxWordBytes {
+			synonyms = append(synonyms, synonym{word: display, index: uint32(i)})
+		}
+	}
+	slices.SortStableFunc(synonyms, func(x, y synonym) int { return starDictCompare(x.word, y.word) })
+	if len(synonyms) > 0 {
+		var syn bytes.Buffer
+		for _, s := range synonyms {
+			syn.WriteString(s.word)
+			syn.WriteByte(0)
+			binary.Write(&syn, binary.BigEndian, s.index)
+		}
+		if err := os.WriteFile(b.path(".syn"), syn.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", b.path(".syn"), err)
+		}
+	}
+
+	// .ifo values are single lines; no date is written so exports are reproducible
+	var ifo strings.Builder
+	ifo.WriteString("StarDict's dict ifo file\nversion=2.4.2\n")
+	fmt.Fprintf(&ifo, "bookname=%s\n", strings.ReplaceAll(b.bookname, "\n", " "))
+	fmt.Fprintf(&ifo, "wordcount=%d\n", len(b.words))
+	if len(synonyms) > 0 {
+		fmt.Fprintf(&ifo, "synwordcount=%d\n", len(synonyms))
+	}
+	fmt.Fprintf(&ifo, "idxfilesize=%d\n", idx.Len())
+	fmt.Fprintf(&ifo, "description=%s\n", strings.ReplaceAll(b.description, "\n", "<br>"))
+	ifo.WriteString("sametypesequence=h\n")
+	if err := os.WriteFile(b.path(".ifo"), []byte(ifo.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", b.path(".ifo"), err)
+	}
+
+	if err := utils.DictzipFile(b.path(".dict"), b.path(".dict.dz")); err != nil {
+		return fmt.Errorf("failed to compress %s: %w", b.path(".dict"), err)
+	}
+	return os.Remove(b.path(".dict"))
+}
+
+// starDictCompare is the order StarDict requires of .idx and .syn files: ASCII
+// case-insensitive first (g_ascii_strcasecmp), then byte order to break ties.
+func starDictCompare(a, b string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ca, cb := asciiLower(a[i]), asciiLower(b[i])
+		if ca != cb {
+			return int(ca) - int(cb)
+		}
+	}
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
+
+func asciiLower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
@@ -0,0 +1,195 @@
+package code
+
+import (
+	"bufio"
+	"fmt"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// CallExportTEI writes every selected dictionary as a TEI Lex-0 document,
+// exports/tei/<name>.tei.xml, for archiving and comparison by linguists. The teiHeader
+// is built from the dictionary's metadata. Each key becomes an <entry> with its lemma
+// <form> in the Ӏ spelling and, for structured (JSON) sources, the WordObject as
+// <gramGrp> (Type), one <sense> per definition with <cit type="example"> examples,
+// cognates as dialectal variant <form>s, and synonyms and the redirect as <xr>. The
+// other sources get one <sense> per line of their Phase 03 HTML.
+func CallExportTEI(run *PipelineRun) error {
+	dictionaries, err := selectExportDictionaries(run)
+	if err != nil {
+		return err
+	}
+	distDir := run.Config.ExportDir("tei")
+	if err := os.MkdirAll(distDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	err = forEachExportDictionary(run, "tei", dictionaries, func(_ int, d exportDictionary) error {
+		return exportTEIDictionary(run.Config, d, distDir)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("TEI export complete: %d dictionaries in %s\n", len(dictionaries), distDir)
+	return nil
+}
+
+// teiWriter writes the entries of one TEI document.
+type teiWriter struct {
+	w                *bufio.Writer
+	fromLang, toLang string         // xml:lang of headwords and examples, and of definitions
+	ids              map[string]int // key → entry number, for xr targets
+}
+
+// teiEntryID returns the xml:id of the n-th entry.
+func teiEntryID(n int) string {
+	return fmt.Sprintf("e%06d", n)
+}
+
+// exportTEIDictionary writes the TEI document of one dictionary.
+func exportTEIDictionary(config PipelineConfig, d exportDictionary, distDir string) error {
+	source, err := readExportSource(config, d)
+	if err != nil {
+		return err
+	}
+	words := source.Words
+	lines := make(map[string][]string)
+	for key, values := range source.HTML {
+		for _, value := range values {
+			lines[key] = append(lines[key], utils.HTMLToTextLines(value)...)
+		}
+	}
+
+	keys := slices.Sorted(maps.Keys(words))
+	if !source.Structured {
+		keys = slices.Sorted(maps.Keys(lines))
+	}
+
+	path := filepath.Join(distDir, d.Name+".tei.xml")
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+	t := &teiWriter{w: bufio.NewWriter(f), fromLang: languageTag(d.FromLang), toLang: languageTag(d.ToLang), ids: make(map[string]int, len(keys))}
+	for i, key := range keys {
+		t.ids[key] = i + 1
+	}
+
+	t.writeHeader(d)
+	for i, key := range keys {
+		if source.Structured {
+			t.writeWordEntry(i+1, key, words[key])
+		} else {
+			t.writeTextEntry(i+1, key, lines[key])
+		}
+	}
+	t.w.WriteString("</body>\n</text>\n</TEI>\n")
+
+	if err := t.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Printf("  %s: %d entries\n", filepath.Base(path), len(keys))
+	return nil
+}
+
+// writeHeader opens the document and writes the teiHeader from the dictionary's metadata.
+func (t *teiWriter) writeHeader(d exportDictionary) {
+	esc := utils.EscapeXML
+	t.w.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	t.w.WriteString(`<?xml-model href="https://dariah-eric.github.io/lexicalresources/pages/TEILex0/TEILex0.rng" type="application/xml" schematypens="http://relaxng.org/ns/structure/1.0"?>` + "\n")
+	fmt.Fprintf(t.w, "<TEI xmlns=\"http://www.tei-c.org/ns/1.0\" xml:lang=\"%s\">\n", t.toLang)
+	t.w.WriteString("<teiHeader>\n<fileDesc>\n")
+	// The order of the fileDesc children is fixed by the schema
+	fmt.Fprintf(t.w, "<titleStmt><title>%s</title></titleStmt>\n", esc(d.Title))
+	fmt.Fprintf(t.w, "<editionStmt><edition n=\"%d\">Pipeline version %d</edition></editionStmt>\n", PipelineVersion, PipelineVersion)
+	fmt.Fprintf(t.w, "<extent><measure unit=\"entry\" quantity=\"%d\">%d entries</measure></extent>\n", len(t.ids), len(t.ids))
+	fmt.Fprintf(t.w, "<publicationStmt><p>Converted by learn-circassian-helper from %s (dictionary %d).</p></publicationStmt>\n", esc(d.Desc.FileName), d.Id)
+	fmt.Fprintf(t.w, "<sourceDesc><p>%s (%s–%s dictionary).</p></sourceDesc>\n", esc(d.Title), esc(languageName(d.FromLang)), esc(languageName(d.ToLang)))
+	t.w.WriteString("</fileDesc>\n<profileDesc>\n<langUsage>\n")
+	fmt.Fprintf(t.w, "<language ident=\"%s\">%s</language>\n", t.fromLang, esc(languageName(d.FromLang)))
+	if t.toLang != t.fromLang {
+		fmt.Fprintf(t.w, "<language ident=\"%s\">%s</language>\n", t.toLang, esc(languageName(d.ToLang)))
+	}
+	t.w.WriteString("</langUsage>\n</profileDesc>\n</teiHeader>\n<text>\n<body>\n")
+}
+
+// writeForm writes the lemma form of an entry.
+func (t *teiWriter) writeForm(key string) {
+	fmt.Fprintf(t.w, "<form type=\"lemma\"><orth>%s</orth></form>", utils.EscapeXML(utils.ConvertCircassian1ToPalochka(key)))
+}
+
+// writeTextEntry writes an entry of a plain or HTML source, one sense per line.
+func (t *teiWriter) writeTextEntry(n int, key string, lines []string) {
+	fmt.Fprintf(t.w, "<entry xml:id=\"%s\" xml:lang=\"%s\">", teiEntryID(n), t.fromLang)
+	t.writeForm(key)
+	for i, line := range lines {
+		fmt.Fprintf(t.w, "<sense xml:id=\"%s.s%d\" n=\"%d\"><def xml:lang=\"%s\">%s</def></sense>", teiEntryID(n), i+1, i+1, t.toLang, utils.EscapeXML(line))
+	}
+	t.w.WriteString("</entry>\n")
+}
+
+// writeWordEntry writes the entry of a structured source.
+func (t *teiWriter) writeWordEntry(n int, key string, w *modals.WordObject) {
+	esc := utils.EscapeXML
+	fmt.Fprintf(t.w, "<entry xml:id=\"%s\" xml:lang=\"%s\">", teiEntryID(n), t.fromLang)
+	t.writeForm(key)
+	for _, c := range w.Cognates {
+		fmt.Fprintf(t.w, "<form type=\"variant\"><usg type=\"geographic\">%s</usg><orth>%s</orth></form>",
+			esc(c.Dialect), esc(utils.ConvertCircassian1ToPalochka(c.Word)))
+	}
+	if w.Type != "" {
+		fmt.Fprintf(t.w, "<gramGrp><gram type=\"pos\">%s</gram></gramGrp>", esc(w.Type))
+	}
+	if w.Derivation != "" {
+		fmt.Fprintf(t.w, "<etym>%s</etym>", esc(w.Derivation))
+	}
+
+	for i, def := range w.Definitions {
+		fmt.Fprintf(t.w, "<sense xml:id=\"%s.s%d\" n=\"%d\">", teiEntryID(n), i+1, i+1)
+		if meaning := teiText(def.Meaning); meaning != "" {
+			fmt.Fprintf(t.w, "<def xml:lang=\"%s\">%s</def>", t.toLang, esc(meaning))
+		}
+		for _, ex := range def.Examples {
+			fmt.Fprintf(t.w, "<cit type=\"example\"><quote xml:lang=\"%s\">%s</quote>", t.fromLang, esc(teiText(ex.Sentence)))
+			if ex.Translation != "" {
+				fmt.Fprintf(t.w, "<cit type=\"translation\" xml:lang=\"%s\"><quote>%s</quote></cit>", t.toLang, esc(teiText(ex.Translation)))
+			}
+			t.w.WriteString("</cit>")
+		}
+		t.w.WriteString("</sense>")
+	}
+
+	for _, synonym := range w.Synonyms {
+		t.writeXR("synonymy", synonym)
+	}
+	if w.Redirect != "" {
+		t.writeXR("related", w.Redirect)
+	}
+	t.w.WriteString("</entry>\n")
+}
+
+// writeXR writes a cross-reference, pointing at the entry of the word when the
+// dictionary has one.
+func (t *teiWriter) writeXR(xrType, word string) {
+	target := ""
+	if n, ok := t.ids[strings.ToLower(word)]; ok {
+		target = fmt.Sprintf(" target=\"#%s\"", teiEntryID(n))
+	}
+	fmt.Fprintf(t.w, "<xr type=\"%s\"><ref type=\"entry\"%s>%s</ref></xr>", xrType, target, utils.EscapeXML(word))
+}
+
+// teiText flattens Phase 02 text for TEI: |bold| markers are dropped and line breaks
+// and tabs collapsed.
+func teiText(text string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(text, "|", "")), " ")
+}
@@ -0,0 +1,110 @@
+package code
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// teiLex0Schema is the RelaxNG schema of the TEI Lex-0 subset the exporter writes.
+var teiLex0Schema = filepath.Join("testdata", "schemas", "tei-lex0-export.rng")
+
+// teiFileDescOrder is the order of the fileDesc children in TEI.
+var teiFileDescOrder = []string{"titleStmt", "editionStmt", "extent", "publicationStmt", "seriesStmt", "notesStmt", "sourceDesc"}
+
+func TestExportTEI(t *testing.T) {
+	run := buildTestPipeline(t, 1)
+	if err := CallExportTEI(run); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := filepath.Glob(filepath.Join(run.Config.ExportDir("tei"), "*.tei.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("exported %d documents, want one for each of dictionaries 3, 4 and 7", len(paths))
+	}
+
+	for _, path := range paths {
+		children, err := xmlChildren(path, "fileDesc")
+		if err != nil {
+			t.Fatalf("%s: %v", filepath.Base(path), err)
+		}
+		last := -1
+		for _, name := range children {
+			position := slices.Index(teiFileDescOrder, name)
+			if position < last {
+				t.Errorf("%s: fileDesc children %v are not in the order %v", filepath.Base(path), children, teiFileDescOrder)
+				break
+			}
+			last = position
+		}
+	}
+
+	validateRelaxNG(t, teiLex0Schema, paths)
+}
+
+// xmlChildren returns the names of the child elements of the first element called
+// parent in an XML file.
+func xmlChildren(path string, parent string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	decoder := xml.NewDecoder(f)
+	depth, parentDepth := 0, -1
+	var children []string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if parentDepth < 0 && t.Name.Local == parent {
+				parentDepth = depth
+			} else if depth == parentDepth+1 && parentDepth > 0 {
+				children = append(children, t.Name.Local)
+			}
+		case xml.EndElement:
+			if depth == parentDepth {
+				return children, nil
+			}
+			depth--
+		}
+	}
+	return nil, errors.New("no " + parent + " element")
+}
+
+// validateRelaxNG validates XML files against a RelaxNG schema with jing or, failing
+// that, xmllint. A missing schema fails the test; it is only skipped when neither
+// validator is installed.
+func validateRelaxNG(t *testing.T, schema string, paths []string) {
+	t.Helper()
+	if _, err := os.Stat(schema); err != nil {
+		t.Fatalf("schema %s: %v", schema, err)
+	}
+
+	var cmd *exec.Cmd
+	if jing, err := exec.LookPath("jing"); err == nil {
+		cmd = exec.Command(jing, append([]string{schema}, paths...)...)
+	} else if xmllint, err := exec.LookPath("xmllint"); err == nil {
+		cmd = exec.Command(xmllint, append([]string{"--noout", "--relaxng", schema}, paths...)...)
+	} else {
+		t.Skip("neither jing nor xmllint is installed, skipping schema validation")
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("the exported files do not validate against %s: %v\n%s", schema, err, output)
+	}
+}
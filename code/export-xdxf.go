@@ -0,0 +1,269 @@
+package code
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// CallExportXDXF writes every selected dictionary as an XDXF file, exports/xdxf/<name>.xdxf,
+// in the logical format of the XDXF standard. Articles (<ar>) list the key in its "1"
+// and its Ӏ spelling (<k>). Dictionaries with structured (JSON) sources are built from
+// their Phase 02 WordObjects: Type becomes <gr>, each definition a <def> with its
+// <deftext> and <ex> examples, synonyms <kref type="syn">, cognates a <co> comment
+// each and the derivation <etm>. The
+// others are built from their Phase 03 HTML, one <def> per block, keeping bold, italics
+// and colors and escaping everything else as text.
+func CallExportXDXF(run *PipelineRun) error {
+	dictionaries, err := selectExportDictionaries(run)
+	if err != nil {
+		return err
+	}
+	distDir := run.Config.ExportDir("xdxf")
+	if err := os.MkdirAll(distDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	err = forEachExportDictionary(run, "xdxf", dictionaries, func(_ int, d exportDictionary) error {
+		return exportXDXFDictionary(run.Config, d, distDir)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("XDXF export complete: %d dictionaries in %s\n", len(dictionaries), distDir)
+	return nil
+}
+
+// exportXDXFDictionary writes the XDXF file of one dictionary.
+func exportXDXFDictionary(config PipelineConfig, d exportDictionary, distDir string) error {
+	source, err := readExportSource(config, d)
+	if err != nil {
+		return err
+	}
+	articles := make(map[string]string) // key → inner XML of its <ar> after the keys
+	if source.Structured {
+		for key, w := range source.Words {
+			articles[key] = wordObjectToXDXF(w)
+		}
+	} else {
+		for key, values := range source.HTML {
+			var sb strings.Builder
+			for _, value := range values {
+				for _, text := range htmlToXDXF(value) {
+					sb.WriteString("<def><deftext>" + text + "</deftext></def>")
+				}
+			}
+			articles[key] = sb.String()
+		}
+	}
+
+	path := filepath.Join(distDir, d.Name+".xdxf")
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	w.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	w.WriteString(`<!DOCTYPE xdxf SYSTEM "https://raw.github.com/soshial/xdxf_makedict/master/format_standard/xdxf_strict.dtd">` + "\n")
+	fmt.Fprintf(w, "<xdxf lang_from=\"%s\" lang_to=\"%s\" format=\"logical\" revision=\"033\">\n",
+		strings.ToUpper(languageCode3(d.FromLang)), strings.ToUpper(languageCode3(d.ToLang)))
+	w.WriteString("<meta_info>\n")
+	fmt.Fprintf(w, "<title>%s</title>\n", utils.EscapeXML(d.Title))
+	fmt.Fprintf(w, "<full_title>%s</full_title>\n", utils.EscapeXML(fmt.Sprintf("%s [%s→%s]", d.Title, d.FromLang, d.ToLang)))
+	fmt.Fprintf(w, "<description>%s</description>\n", utils.EscapeXML(fmt.Sprintf("%s, %s to %s. Converted from %s.", d.Title, d.FromLang, d.ToLang, d.Desc.FileName)))
+	w.WriteString("</meta_info>\n<lexicon>\n")
+
+	count := 0
+	for _, key := range slices.Sorted(maps.Keys(articles)) {
+		if articles[key] == "" {
+			continue
+		}
+		w.WriteString("<ar><k>" + utils.EscapeXML(key) + "</k>")
+		if display := utils.ConvertCircassian1ToPalochka(key); display != key {
+			w.WriteString("<k>" + utils.EscapeXML(display) + "</k>")
+		}
+		w.WriteString(articles[key] + "</ar>\n")
+		count++
+	}
+	w.WriteString("</lexicon>\n</xdxf>\n")
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Printf("  %s: %d articles\n", filepath.Base(path), count)
+	return nil
+}
+
+// wordObjectToXDXF returns the XDXF of a structured entry: the part of speech, one
+// <def> per definition with its examples, then the redirect, a <co> comment per
+// cognate, the synonyms and the derivation.
+func wordObjectToXDXF(w *modals.WordObject) string {
+	var sb strings.Builder
+	sb.WriteString("<def>")
+	if w.Type != "" {
+		sb.WriteString("<gr>" + utils.EscapeXML(w.Type) + "</gr>")
+	}
+	for _, def := range w.Definitions {
+		sb.WriteString("<def>")
+		if meaning := strings.Join(htmlToXDXF(meaningToHTML(def.Meaning)), " "); meaning != "" {
+			sb.WriteString("<deftext>" + meaning + "</deftext>")
+		}
+		for _, ex := range def.Examples {
+			sb.WriteString(`<ex type="exm"><ex_orig>` + xdxfBoldMarkers(ex.Sentence) + "</ex_orig>")
+			if ex.Translation != "" {
+				sb.WriteString("<ex_tran>" + xdxfBoldMarkers(ex.Translation) + "</ex_tran>")
+			}
+			sb.WriteString("</ex>")
+		}
+		sb.WriteString("</def>")
+	}
+	if w.Redirect != "" {
+		sb.WriteString("<sr><kref>" + utils.EscapeXML(w.Redirect) + "</kref></sr>")
+	}
+	for _, c := range w.Cognates {
+		sb.WriteString("<co>" + utils.EscapeXML(xdxfCognate(c)) + "</co>")
+	}
+	if len(w.Synonyms) > 0 {
+		sb.WriteString("<sr>")
+		for _, synonym := range w.Synonyms {
+			sb.WriteString(`<kref type="syn">` + utils.EscapeXML(synonym) + "</kref>")
+		}
+		sb.WriteString("</sr>")
+	}
+	if w.Derivation != "" {
+		sb.WriteString("<etm>" + utils.EscapeXML(w.Derivation) + "</etm>")
+	}
+	sb.WriteString("</def>")
+	return sb.String()
+}
+
+// xdxfCognate returns the comment text of a cognate, "cognate (dialect): word" or
+// "cognate: word", which the xdxf converter reads back (xdxfCognateRegex).
+func xdxfCognate(c modals.Cognate) string {
+	if c.Dialect == "" {
+		return "cognate: " + c.Word
+	}
+	return "cognate (" + c.Dialect + "): " + c.Word
+}
+
+// xdxfBoldMarkers escapes text and turns its |bold| markers into <b>.
+func xdxfBoldMarkers(text string) string {
+	return pipeMarkerRegex.ReplaceAllString(utils.EscapeXML(text), "<b>$1</b>")
+}
+
+// htmlToXDXF converts an HTML fragment to the contents of XDXF <deftext> elements, one
+// per top-level block (div, p, heading, list item, br) plus one for text between blocks.
+// Bold, italic, underlined, sub- and superscript text and font colors become the XDXF
+// formatting tags; other markup is dropped and its text kept.
+func htmlToXDXF(fragment string) []string {
+	decoder := xml.NewDecoder(strings.NewReader("<root>" + utils.HTMLToXHTML(fragment) + "</root>"))
+	var texts []string
+	var current strings.Builder
+	var closers []string // closing tags of the open elements, "" when nothing was emitted
+	blockDepth := 0
+
+	// A block only starts a new <deftext> when no formatting is open around it
+	flush := func() {
+		if slices.ContainsFunc(closers, func(c string) bool { return c != "" }) {
+			current.WriteString(" ")
+			return
+		}
+		if text := strings.TrimSpace(current.String()); text != "" {
+			texts = append(texts, text)
+		}
+		current.Reset()
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break // io.EOF; HTMLToXHTML output is well-formed
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "root" {
+				continue
+			}
+			block := utils.HTMLBlockElements[t.Name.Local]
+			if block {
+				if blockDepth == 0 {
+					flush()
+				} else {
+					current.WriteString(" ")
+				}
+				blockDepth++
+			}
+			open, closer := xdxfFormatting(t)
+			current.WriteString(open)
+			closers = append(closers, closer)
+		case xml.EndElement:
+			if t.Name.Local == "root" {
+				continue
+			}
+			current.WriteString(closers[len(closers)-1])
+			closers = closers[:len(closers)-1]
+			if utils.HTMLBlockElements[t.Name.Local] {
+				blockDepth--
+				if blockDepth == 0 {
+					flush()
+				}
+			}
+		case xml.CharData:
+			current.WriteString(utils.EscapeXML(string(t)))
+		}
+	}
+	flush()
+	return texts
+}
+
+// xdxfFormatting returns the XDXF tags that render an XHTML element, if any.
+func xdxfFormatting(e xml.StartElement) (open, closer string) {
+	wrap := func(tag string) {
+		open += "<" + tag + ">"
+		closer = "</" + tag + ">" + closer
+	}
+	switch e.Name.Local {
+	case "b", "strong", "h1", "h2", "h3", "h4", "h5", "h6":
+		wrap("b")
+	case "i", "em":
+		wrap("i")
+	case "u", "sub", "sup":
+		wrap(e.Name.Local)
+	}
+	for _, attr := range e.Attr {
+		switch attr.Name.Local {
+		case "color":
+			open += `<c c="` + utils.EscapeXML(attr.Value) + `">`
+			closer = "</c>" + closer
+		case "style":
+			for _, declaration := range strings.Split(attr.Value, ";") {
+				property, value, _ := strings.Cut(declaration, ":")
+				value = strings.TrimSpace(value)
+				switch strings.TrimSpace(property) {
+				case "font-weight":
+					if value == "bold" {
+						wrap("b")
+					}
+				case "font-style":
+					if value == "italic" {
+						wrap("i")
+					}
+				}
+			}
+		}
+	}
+	return open, closer
+}
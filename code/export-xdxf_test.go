@@ -0,0 +1,126 @@
+package code
+
+import (
+	"learn-circassian-helper/modals"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestExportXDXFRoundTrip exports the structured fixture dictionaries as XDXF, reads the
+// files back with the xdxf converter and checks that every entry, with its cognates
+// (<co>) and synonyms (<sr><kref>), comes back as it was in Phase 02, whitespace aside.
+func TestExportXDXFRoundTrip(t *testing.T) {
+	run := buildTestPipeline(t, 1)
+	if err := CallExportXDXF(run); err != nil {
+		t.Fatal(err)
+	}
+	dictionaries, err := selectExportDictionaries(run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checked := 0
+	for _, d := range dictionaries {
+		source, err := readExportSource(run.Config, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !source.Structured {
+			continue
+		}
+		f, err := os.Open(filepath.Join(run.Config.ExportDir("xdxf"), d.Name+".xdxf"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dict, _, err := xdxfConverter{}.Convert(f, d.Desc)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", d.Name, err)
+		}
+		got := dict.(*modals.DictObjectJsonObj).WordsToJsonObjMap
+
+		if keys, want := slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(source.Words)); !slices.Equal(keys, want) {
+			t.Errorf("%s: keys %v, want %v", d.Name, keys, want)
+		}
+		for key, want := range source.Words {
+			if w, ok := got[key]; ok && !reflect.DeepEqual(normalizeXDXFWord(w), normalizeXDXFWord(want)) {
+				t.Errorf("%s: %s = %+v, want %+v", d.Name, key, normalizeXDXFWord(w), normalizeXDXFWord(want))
+			}
+		}
+		checked++
+	}
+	if checked != 2 {
+		t.Errorf("checked %d structured dictionaries, want dictionaries 3 and 4", checked)
+	}
+}
+
+// TestXDXFConverterVisual reads articles in the visual format of older XDXF files,
+// where the definitions are the text of the article.
+func TestXDXFConverterVisual(t *testing.T) {
+	const visual = `<?xml version="1.0" encoding="UTF-8"?>
+<xdxf lang_from="ADY" lang_to="ENG" format="visual">
+<full_name>Visual fixture</full_name>
+<ar><k>Унэ</k>
+<gr>noun</gr>
+<b>house</b>, home
+<ex>Ар унэ дахэ. <ex_tran>It is a nice house.</ex_tran></ex>
+<co>cognate (kabardian): унэ</co>
+<sr><kref type="syn">псэуп1э</kref></sr>
+</ar>
+<ar><k>уна</k>
+<sr><kref>унэ</kref></sr>
+</ar>
+<ar><k>Ӏэгу</k>palm<br/>yard</ar>
+<ar><k>нэкӀ</k>
+</ar>
+</xdxf>
+`
+	desc := modals.DictionaryDescriptor{Id: 90, FileName: "90-Ady-En.xdxf", FromLang: "Ady", ToLang: "En", Format: "json", Converter: "xdxf"}
+	dict, report, err := xdxfConverter{}.Convert(strings.NewReader(visual), desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]*modals.WordObject{
+		"унэ": {
+			Type:        "noun",
+			Definitions: []modals.Definition{{Meaning: "|house|, home", Examples: []modals.Example{{Sentence: "Ар унэ дахэ.", Translation: "It is a nice house."}}}},
+			Cognates:    []modals.Cognate{{Dialect: "kabardian", Word: "унэ"}},
+			Synonyms:    []string{"псэуп1э"},
+		},
+		"уна":  {Redirect: "унэ"},
+		"1эгу": {Definitions: []modals.Definition{{Meaning: "palm\nyard"}}},
+	}
+	if got := dict.(*modals.DictObjectJsonObj).WordsToJsonObjMap; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+	if len(report.Issues) != 1 {
+		t.Errorf("%d parse issues, want one for the empty article", len(report.Issues))
+	}
+}
+
+// normalizeXDXFWord returns a copy of an entry with its whitespace collapsed and empty
+// lists as nil, the differences an XDXF round trip may introduce.
+func normalizeXDXFWord(w *modals.WordObject) modals.WordObject {
+	space := func(s string) string { return strings.Join(strings.Fields(s), " ") }
+	n := modals.WordObject{Type: space(w.Type), Redirect: space(w.Redirect), Derivation: space(w.Derivation)}
+	for _, def := range w.Definitions {
+		d := modals.Definition{Meaning: space(def.Meaning)}
+		for _, ex := range def.Examples {
+			d.Examples = append(d.Examples, modals.Example{Sentence: space(ex.Sentence), Translation: space(ex.Translation)})
+		}
+		n.Definitions = append(n.Definitions, d)
+	}
+	for _, c := range w.Cognates {
+		n.Cognates = append(n.Cognates, modals.Cognate{Dialect: space(c.Dialect), Word: space(c.Word)})
+	}
+	for _, synonym := range w.Synonyms {
+		n.Synonyms = append(n.Synonyms, space(synonym))
+	}
+	return n
+}
@@ -0,0 +1,384 @@
+package code
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// yomitanTermBankSize is the number of terms per term_bank_N.json file.
+const yomitanTermBankSize = 10000
+
+// CallExportYomitan writes every selected dictionary as a Yomitan (formerly Yomichan)
+// dictionary, exports/yomitan/<name>.zip, for pop-up lookups in the browser. The ZIP
+// holds index.json, tag_bank_1.json and term_bank_N.json files.
+//
+// Dictionaries with structured (JSON) sources are built from their Phase 02
+// WordObjects: WordObject.Type becomes a part-of-speech definition tag and every
+// definition a structured-content gloss with its examples in a list. The others are
+// built from their Phase 03 HTML. Every key is added with its "1" spelling and its
+// display spelling with Ӏ, so page text in either spelling matches.
+func CallExportYomitan(run *PipelineRun) error {
+	dictionaries, err := selectExportDictionaries(run)
+	if err != nil {
+		return err
+	}
+	distDir := run.Config.ExportDir("yomitan")
+	if err := os.MkdirAll(distDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	err = forEachExportDictionary(run, "yomitan", dictionaries, func(_ int, d exportDictionary) error {
+		return exportYomitanDictionary(run.Config, d, distDir)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Yomitan export complete: %d dictionaries in %s\n", len(dictionaries), distDir)
+	return nil
+}
+
+// yomitanIndex is the index.json of a Yomitan dictionary (format 3).
+type yomitanIndex struct {
+	Title          string `json:"title"`
+	Revision       string `json:"revision"`
+	Sequenced      bool   `json:"sequenced"`
+	Format         int    `json:"format"`
+	Description    string `json:"description"`
+	SourceLanguage string `json:"sourceLanguage"`
+	TargetLanguage string `json:"targetLanguage"`
+}
+
+// yomitanTerm is one row of a term bank. It is written as the JSON array
+// [term, reading, definitionTags, rules, score, glossary, sequence, termTags].
+type yomitanTerm struct {
+	term     string
+	tags     string
+	glossary []any
+	sequence int
+}
+
+func (t yomitanTerm) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{t.term, "", t.tags, "", 0, t.glossary, t.sequence, ""})
+}
+
+// yomitanNode is an element of Yomitan structured content. Content is a string, a
+// node or a list of both.
+type yomitanNode struct {
+	Tag     string            `json:"tag"`
+	Content any               `json:"content,omitempty"`
+	Style   *yomitanStyle     `json:"style,omitempty"`
+	Data    map[string]string `json:"data,omitempty"`
+	Lang    string            `json:"lang,omitempty"`
+}
+
+// yomitanStyle holds the structured-content styles the Phase 03 HTML uses. Margins are in em.
+type yomitanStyle struct {
+	FontWeight   string  `json:"fontWeight,omitempty"`
+	FontStyle    string  `json:"fontStyle,omitempty"`
+	MarginLeft   float64 `json:"marginLeft,omitempty"`
+	MarginBottom float64 `json:"marginBottom,omitempty"`
+}
+
+// yomitanFile is a JSON file of the dictionary ZIP.
+type yomitanFile struct {
+	name string
+	data any
+}
+
+// yomitanGloss wraps structured content as a glossary item.
+func yomitanGloss(content any) map[string]any {
+	return map[string]any{"type": "structured-content", "content": content}
+}
+
+// exportYomitanDictionary builds the glossaries of one dictionary and writes its ZIP.
+func exportYomitanDictionary(config PipelineConfig, d exportDictionary, distDir string) error {
+	source, err := readExportSource(config, d)
+	if err != nil {
+		return err
+	}
+	glossaries := make(map[string][]any)
+	partsOfSpeech := make(map[string]string) // key → space-separated tag names
+	tagNames := make(map[string]bool)
+
+	if source.Structured {
+		lang := languageTag(d.FromLang)
+		for key, w := range source.Words {
+			glossaries[key] = wordObjectToYomitan(w, lang)
+			if names := yomitanTagNames(w.Type); len(names) > 0 {
+				partsOfSpeech[key] = strings.Join(names, " ")
+				for _, name := range names {
+					tagNames[name] = true
+				}
+			}
+		}
+	} else {
+		for key, values := range source.HTML {
+			for _, value := range values {
+				content, err := htmlToYomitan(value)
+				if err != nil {
+					return fmt.Errorf("failed to convert the entry of %q: %w", key, err)
+				}
+				if content != nil {
+					glossaries[key] = append(glossaries[key], yomitanGloss(content))
+				}
+			}
+		}
+	}
+
+	// Both spellings of a key share its sequence number, so Yomitan shows them as one entry
+	var terms []yomitanTerm
+	for i, key := range slices.Sorted(maps.Keys(glossaries)) {
+		if len(glossaries[key]) == 0 {
+			continue
+		}
+		term := yomitanTerm{term: key, tags: partsOfSpeech[key], glossary: glossaries[key], sequence: i + 1}
+		terms = append(terms, term)
+		if display := utils.ConvertCircassian1ToPalochka(key); display != key {
+			term.term = display
+			terms = append(terms, term)
+		}
+	}
+
+	revision, err := utils.HashFile(source.Path)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", source.Path, err)
+	}
+	index := yomitanIndex{
+		Title:          fmt.Sprintf("%s [%s→%s]", d.Title, d.FromLang, d.ToLang),
+		Revision:       revision[:12],
+		Sequenced:      true,
+		Format:         3,
+		Description:    fmt.Sprintf("%s, %s to %s. Converted from %s.", d.Title, d.FromLang, d.ToLang, d.Desc.FileName),
+		SourceLanguage: languageTag(d.FromLang),
+		TargetLanguage: languageTag(d.ToLang),
+	}
+
+	tags := make([][]any, 0, len(tagNames))
+	for _, name := range slices.Sorted(maps.Keys(tagNames)) {
+		tags = append(tags, []any{name, "partOfSpeech", 0, strings.ReplaceAll(name, "-", " "), 0})
+	}
+
+	files := []yomitanFile{
+		{"index.json", index},
+		{"tag_bank_1.json", tags},
+	}
+	for start := 0; start < len(terms); start += yomitanTermBankSize {
+		bank := terms[start:min(start+yomitanTermBankSize, len(terms))]
+		files = append(files, yomitanFile{fmt.Sprintf("term_bank_%d.json", start/yomitanTermBankSize+1), bank})
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		data, err := json.Marshal(file.data)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", file.name, err)
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: exportZipTime})
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", file.name, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to add %s: %w", file.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write ZIP: %w", err)
+	}
+
+	zipPath := filepath.Join(distDir, d.Name+".zip")
+	if err := os.WriteFile(zipPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", zipPath, err)
+	}
+	fmt.Printf("  %s: %d terms\n", filepath.Base(zipPath), len(terms))
+	return nil
+}
+
+// yomitanMaxTagWords is the number of words above which a WordObject.Type is shown
+// as text instead of a tag; some sources put whole notes in it.
+const yomitanMaxTagWords = 3
+
+// yomitanTagNames turns a WordObject.Type into tag names without spaces, brackets or
+// trailing punctuation, e.g. "transitive verb" into "transitive-verb" and
+// "adverb, adjective" into "adverb" and "adjective". It returns nil for an empty Type
+// or one with a part longer than yomitanMaxTagWords.
+func yomitanTagNames(wordType string) []string {
+	var names []string
+	for _, part := range strings.FieldsFunc(strings.ToLower(wordType), func(r rune) bool { return r == ',' || r == ';' || r == '/' }) {
+		words := strings.Fields(strings.Trim(part, " .:()\ufeff"))
+		if len(words) > yomitanMaxTagWords {
+			return nil
+		}
+		if name := strings.Join(words, "-"); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// wordObjectToYomitan returns the glossary of a structured entry: one structured-content
+// gloss per definition with its examples in a list, and one for a Type too long to be
+// a tag, the redirect, cognates, derivation and synonyms. lang is the language of the example sentences.
+func wordObjectToYomitan(w *modals.WordObject, lang string) []any {
+	var glossary []any
+	for _, def := range w.Definitions {
+		meaning, _ := htmlToYomitan(meaningToHTML(def.Meaning))
+		content := []any{yomitanNode{Tag: "div", Content: meaning}}
+		if len(def.Examples) > 0 {
+			var items []any
+			for _, ex := range def.Examples {
+				sentence, _ := htmlToYomitan(formatTextWithBoldMarkers(html.EscapeString(ex.Sentence)))
+				item := []any{yomitanNode{Tag: "span", Content: sentence, Lang: lang}}
+				if ex.Translation != "" {
+					translation, _ := htmlToYomitan(formatTextWithBoldMarkers(html.EscapeString(ex.Translation)))
+					item = append(item, " — ", translation)
+				}
+				items = append(items, yomitanNode{Tag: "li", Content: item})
+			}
+			content = append(content, yomitanNode{Tag: "ul", Content: items, Data: map[string]string{"content": "examples"}})
+		}
+		glossary = append(glossary, yomitanGloss(content))
+	}
+
+	var extra []any
+	addLine := func(label, text string) {
+		extra = append(extra, yomitanNode{Tag: "div", Content: []any{
+			yomitanNode{Tag: "span", Content: label + ": ", Style: &yomitanStyle{FontWeight: "bold"}}, text,
+		}})
+	}
+	if w.Type != "" && len(yomitanTagNames(w.Type)) == 0 {
+		addLine("Type", w.Type)
+	}
+	if w.Redirect != "" {
+		addLine("See", w.Redirect)
+	}
+	if len(w.Cognates) > 0 {
+		cognates := make([]string, len(w.Cognates))
+		for i, c := range w.Cognates {
+			cognates[i] = c.Dialect + ": " + c.Word
+		}
+		addLine("Cognates", strings.Join(cognates, "; "))
+	}
+	if w.Derivation != "" {
+		addLine("Derivation", w.Derivation)
+	}
+	if len(w.Synonyms) > 0 {
+		addLine("Synonyms", strings.Join(w.Synonyms, ", "))
+	}
+	if len(extra) > 0 {
+		glossary = append(glossary, yomitanGloss(extra))
+	}
+	return glossary
+}
+
+// htmlToYomitan converts an HTML fragment to structured content. Headings, paragraphs
+// and divs become divs, inline elements spans; bold, italics and the margins of the
+// Phase 03 HTML are kept as styles. It returns nil for an empty fragment.
+func htmlToYomitan(fragment string) (any, error) {
+	decoder := xml.NewDecoder(strings.NewReader("<root>" + utils.HTMLToXHTML(fragment) + "</root>"))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	// children[i] holds the converted content of stack[i]; stack[0] is the root
+	stack := []*yomitanNode{nil}
+	children := [][]any{nil}
+	for len(stack) > 0 {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, yomitanElement(t))
+			children = append(children, nil)
+		case xml.EndElement:
+			if len(stack) == 1 {
+				return yomitanContent(children[0]), nil
+			}
+			node := stack[len(stack)-1]
+			node.Content = yomitanContent(children[len(children)-1])
+			stack, children = stack[:len(stack)-1], children[:len(children)-1]
+			children[len(children)-1] = append(children[len(children)-1], *node)
+		case xml.CharData:
+			children[len(children)-1] = append(children[len(children)-1], string(t))
+		}
+	}
+	return nil, nil
+}
+
+// yomitanContent returns a single string or node as is, a list otherwise, and nil when empty.
+func yomitanContent(items []any) any {
+	switch len(items) {
+	case 0:
+		return nil
+	case 1:
+		return items[0]
+	}
+	return items
+}
+
+// yomitanElement maps an XHTML element to a structured-content node.
+func yomitanElement(e xml.StartElement) *yomitanNode {
+	node := &yomitanNode{Tag: "span"}
+	style := &yomitanStyle{}
+	switch name := e.Name.Local; name {
+	case "br", "ul", "ol", "li", "table", "thead", "tbody", "tfoot", "tr", "td", "th", "div":
+		node.Tag = name
+	case "p":
+		node.Tag = "div"
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		node.Tag = "div"
+		style.FontWeight = "bold"
+	case "b", "strong":
+		style.FontWeight = "bold"
+	case "i", "em":
+		style.FontStyle = "italic"
+	}
+
+	for _, attr := range e.Attr {
+		if attr.Name.Local != "style" {
+			continue
+		}
+		for _, declaration := range strings.Split(attr.Value, ";") {
+			property, value, _ := strings.Cut(declaration, ":")
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(property) {
+			case "font-weight":
+				style.FontWeight = value
+			case "font-style":
+				style.FontStyle = value
+			case "margin-left":
+				style.MarginLeft = parseEm(value)
+			case "margin-bottom":
+				style.MarginBottom = parseEm(value)
+			}
+		}
+	}
+	if *style != (yomitanStyle{}) {
+		node.Style = style
+	}
+	return node
+}
+
+// parseEm returns the number of a CSS length in em, e.g. 1.5 for "1.5em", or 0.
+func parseEm(value string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(value, "em"), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
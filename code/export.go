@@ -0,0 +1,275 @@
+package code
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"learn-circassian-helper/modals"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ExportOptions configures the export commands, which write the Phase 04 data in
+// the formats of other dictionary software.
+type ExportOptions struct {
+	Dir      string   // defaults to <OutputDir>/exports; every format gets a subfolder
+	Combined bool     // write one dictionary holding every selected source instead of one per source
+	Pairs    []string // language pairs to export, e.g. "Kbd>Ru"; empty means all
+
+	Abbreviations bool   // dsl: also write a <name>_abrv.dsl file explaining the part-of-speech labels
+	WordList      string // anki: file of the words to put in the decks, one per line; empty means all
+	Gzip          bool   // jsonl: write entries.jsonl.gz instead of entries.jsonl
+}
+
+// NormalizeLanguagePair accepts "Kbd-Ru" as a shell-friendly spelling of "Kbd>Ru".
+func NormalizeLanguagePair(pair string) string {
+	return strings.Replace(pair, "-", ">", 1)
+}
+
+// languagePairFileName turns a language pair into a file name, e.g. "Ady/Kbd>Tr" into "Ady+Kbd-Tr".
+func languagePairFileName(pair string) string {
+	return strings.NewReplacer(">", "-", "/", "+").Replace(pair)
+}
+
+// languageTag returns the BCP 47 tag of a manifest language code, e.g. "ru" for "Ru".
+// Mixed codes such as "Ady/Kbd" use their first language.
+func languageTag(code string) string {
+	first, _, _ := strings.Cut(code, "/")
+	return strings.ToLower(first)
+}
+
+// languageCodes3 maps the manifest's language codes to ISO 639-2/3 codes.
+var languageCodes3 = map[string]string{"ady": "ady", "kbd": "kbd", "ar": "ara", "en": "eng", "ru": "rus", "tr": "tur"}
+
+// languageCode3 returns the three-letter ISO 639 code of a manifest language code, e.g.
+// "rus" for "Ru", for formats that require one. Unknown codes are returned lowercased.
+func languageCode3(code string) string {
+	tag := languageTag(code)
+	if code3, ok := languageCodes3[tag]; ok {
+		return code3
+	}
+	return tag
+}
+
+// languageNames are the English names of the manifest's languages.
+var languageNames = map[string]string{"ady": "Adyghe", "kbd": "Kabardian", "ar": "Arabic", "en": "English", "ru": "Russian", "tr": "Turkish"}
+
+// languageName returns the English name of a manifest language code, e.g. "Russian"
+// for "Ru", or the code itself when it is unknown.
+func languageName(code string) string {
+	if name, ok := languageNames[languageTag(code)]; ok {
+		return name
+	}
+	return code
+}
+
+// exportZipTime is the modification time of every file in the exported ZIP archives,
+// so exports are reproducible.
+var exportZipTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// exportDictionary is a merged dictionary chosen for export.
+type exportDictionary struct {
+	modals.DictionaryInfo
+	Desc modals.DictionaryDescriptor
+	Name string // file name stem, e.g. "04-Ady-En_Adam"
+}
+
+// loadMergedDictionaries reads the Phase 04 dictionaries.json, in id order.
+func loadMergedDictionaries(config PipelineConfig) ([]modals.DictionaryInfo, error) {
+	dictsPath := filepath.Join(config.MergedDir(), "dictionaries.json")
+	data, err := os.ReadFile(dictsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dictsPath, err)
+	}
+
+	var dictionaries []modals.DictionaryInfo
+	if err := json.Unmarshal(data, &dictionaries); err != nil {
+		return nil, fmt.Errorf("failed to parse dictionaries JSON: %w", err)
+	}
+	return dictionaries, nil
+}
+
+// selectExportDictionaries returns the merged dictionaries chosen by Config.Only and
+// ExportOptions.Pairs, in id order. Selecting a dictionary or language pair that is
+// not in the merged database is an error.
+func selectExportDictionaries(run *PipelineRun) ([]exportDictionary, error) {
+	merged, err := loadMergedDictionaries(run.Config)
+	if err != nil {
+		return nil, err
+	}
+	descriptors := make(map[int]modals.DictionaryDescriptor, len(run.Manifest.Dictionaries))
+	for _, desc := range run.Manifest.Dictionaries {
+		descriptors[desc.Id] = desc
+	}
+
+	wanted := make(map[int]bool, len(run.Config.Only))
+	for _, id := range run.Config.Only {
+		wanted[id] = true
+	}
+
+	wantedPairs := make(map[string]bool, len(run.Config.Export.Pairs))
+	for _, pair := range run.Config.Export.Pairs {
+		wantedPairs[NormalizeLanguagePair(pair)] = true
+	}
+
+	found := make(map[int]bool, len(merged))
+	foundPairs := make(map[string]bool, len(wantedPairs))
+	selected := make([]exportDictionary, 0, len(merged))
+	for _, info := range merged {
+		if len(wanted) > 0 && !wanted[info.Id] {
+			continue
+		}
+		found[info.Id] = true
+
+		desc, ok := descriptors[info.Id]
+		if !ok {
+			return nil, fmt.Errorf("dictionary %d of dictionaries.json is not in the manifest", info.Id)
+		}
+		if len(wantedPairs) > 0 && !wantedPairs[desc.LanguagePair()] {
+			continue
+		}
+		foundPairs[desc.LanguagePair()] = true
+		name := strings.TrimSuffix(desc.FileName, filepath.Ext(desc.FileName))
+		selected = append(selected, exportDictionary{DictionaryInfo: info, Desc: desc, Name: name})
+	}
+
+	for _, id := range run.Config.Only {
+		if !found[id] {
+			return nil, fmt.Errorf("dictionary %d is not in the merged database", id)
+		}
+	}
+	for pair := range wantedPairs {
+		if !foundPairs[pair] {
+			return nil, fmt.Errorf("no selected dictionary translates %s", pair)
+		}
+	}
+	return selected, nil
+}
+
+// readExportWords streams every merged headword with its entries: the words of
+// merged-database.jsonl in word order, then the phrases too long for it. Multi-word
+// headwords short enough to be words are not repeated.
+func readExportWords(config PipelineConfig, fn func(merged modals.MergedWord) error) error {
+	if err := readMergedWords(filepath.Join(config.MergedDir(), MergedDatabaseFileName), fn); err != nil {
+		return err
+	}
+	return readMergedWords(filepath.Join(config.MergedDir(), MergedPhrasesFileName), func(merged modals.MergedWord) error {
+		if utf8.RuneCountInString(merged.Word) <= maxWordRunes {
+			return nil
+		}
+		return fn(merged)
+	})
+}
+
+// combinedArticle joins the entries of the given dictionaries into one article, each
+// under a heading with its dictionary title. It returns "" when no entry is selected.
+func combinedArticle(entries []modals.MergedDictEntry, titles map[int]string) string {
+	var article strings.Builder
+	for _, entry := range entries {
+		if title, ok := titles[entry.Id]; ok {
+			fmt.Fprintf(&article, "<h3>%s</h3>%s", html.EscapeString(title), entry.Html)
+		}
+	}
+	return article.String()
+}
+
+// loadForms collects the inflected forms of the headwords of the given dictionaries
+// whose converter is a FormsExtractor, read from their Phase 02 files.
+func loadForms(config PipelineConfig, dictionaries []exportDictionary) (map[string][]string, error) {
+	forms := make(map[string][]string)
+	for _, d := range dictionaries {
+		converter, _ := LookupConverter(d.Desc.Converter)
+		extractor, ok := converter.(FormsExtractor)
+		if !ok {
+			continue
+		}
+
+		path := filepath.Join(config.JSONDir(), Phase02FileName(d.Desc))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		var dictObj modals.DictObjectPlainText
+		if err := json.Unmarshal(data, &dictObj); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		for key, values := range dictObj.WordsToPlainTextMap {
+			for _, value := range values {
+				for _, form := range extractor.Forms(key, value) {
+					if !slices.Contains(forms[key], form) {
+						forms[key] = append(forms[key], form)
+					}
+				}
+			}
+		}
+	}
+	return forms, nil
+}
+
+// forEachExportDictionary calls fn for every dictionary on up to Config.Jobs
+// goroutines, like forEachDictionary, and records the outcome of step for each in the
+// run summary.
+func forEachExportDictionary(run *PipelineRun, step string, dictionaries []exportDictionary, fn func(i int, d exportDictionary) error) error {
+	descriptors := make([]modals.DictionaryDescriptor, len(dictionaries))
+	for i, d := range dictionaries {
+		descriptors[i] = d.Desc
+	}
+	return forEachDictionary(run.Config.Jobs, descriptors, func(i int, desc modals.DictionaryDescriptor) error {
+		if err := fn(i, dictionaries[i]); err != nil {
+			return run.Summary.Fail(desc, step, err)
+		}
+		run.Summary.Succeeded(desc, step)
+		return nil
+	})
+}
+
+// exportSource holds the entries a dictionary is exported from.
+type exportSource struct {
+	Path       string                        // the file they were read from
+	Structured bool                          // the dictionary is in the json format
+	Words      map[string]*modals.WordObject // key → Phase 02 WordObject, for structured sources
+	HTML       map[string][]string           // key → Phase 03 HTML, for the others
+}
+
+// readExportSource reads the entries of a dictionary: the Phase 02 WordObjects of a
+// structured (JSON) source, whose structure the formats can keep, or else its Phase 03
+// HTML. Keys without a WordObject are left out.
+func readExportSource(config PipelineConfig, d exportDictionary) (*exportSource, error) {
+	format, _ := modals.ParseDictFormat(d.Desc.Format)
+	source := &exportSource{Structured: format == modals.DictFormatJSON}
+	if source.Structured {
+		source.Path = filepath.Join(config.JSONDir(), Phase02FileName(d.Desc))
+	} else {
+		source.Path = filepath.Join(config.HTMLDir(), Phase02FileName(d.Desc))
+	}
+	data, err := os.ReadFile(source.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", source.Path, err)
+	}
+
+	if !source.Structured {
+		var dictObj modals.DictObjectHTML
+		if err := json.Unmarshal(data, &dictObj); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", source.Path, err)
+		}
+		source.HTML = dictObj.WordsToHtmlMap
+		return source, nil
+	}
+
+	var dictObj modals.DictObjectJsonObj
+	if err := json.Unmarshal(data, &dictObj); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source.Path, err)
+	}
+	source.Words = make(map[string]*modals.WordObject, len(dictObj.WordsToJsonObjMap))
+	for key, w := range dictObj.WordsToJsonObjMap {
+		if w != nil {
+			source.Words[key] = w
+		}
+	}
+	return source, nil
+}
@@ -0,0 +1,190 @@
+package code
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"learn-circassian-helper/modals"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ManifestFileName is the name of the dictionary manifest inside the input directory.
+const ManifestFileName = "dictionaries-manifest.json"
+
+// LoadManifest reads and validates the dictionary manifest. All problems are
+// collected and returned together so a broken manifest can be fixed in one pass:
+//   - duplicate dictionary ids or file names
+//   - unknown formats or converter names
+//   - invalid preprocess rewrite rules
+//   - priority lists naming unknown dictionaries or language pairs
+func LoadManifest(manifestPath string) (*modals.DictionaryManifest, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", manifestPath, err)
+	}
+
+	var manifest modals.DictionaryManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", manifestPath, err)
+	}
+
+	if err := validateManifest(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s:\n%w", manifestPath, err)
+	}
+	return &manifest, nil
+}
+
+func validateManifest(manifest *modals.DictionaryManifest) error {
+	var errs []error
+	seenIDs := make(map[int]string)
+	seenFiles := make(map[string]bool)
+
+	for _, desc := range manifest.Dictionaries {
+		if desc.FileName == "" {
+			errs = append(errs, fmt.Errorf("dictionary %d: missing file_name", desc.Id))
+			continue
+		}
+		if other, exists := seenIDs[desc.Id]; exists {
+			errs = append(errs, fmt.Errorf("dictionary %d: id used by both %s and %s", desc.Id, other, desc.FileName))
+		} else {
+			seenIDs[desc.Id] = desc.FileName
+		}
+		if seenFiles[desc.FileName] {
+			errs = append(errs, fmt.Errorf("dictionary %d: file %s listed more than once", desc.Id, desc.FileName))
+		}
+		seenFiles[desc.FileName] = true
+
+		if _, err := modals.ParseDictFormat(desc.Format); err != nil {
+			errs = append(errs, fmt.Errorf("dictionary %d (%s): %w", desc.Id, desc.FileName, err))
+		}
+		if _, ok := LookupConverter(desc.Converter); !ok {
+			errs = append(errs, fmt.Errorf("dictionary %d (%s): unknown converter %q", desc.Id, desc.FileName, desc.Converter))
+		}
+		if _, err := compileRewriteRules(desc.Preprocess); err != nil {
+			errs = append(errs, fmt.Errorf("dictionary %d (%s): %w", desc.Id, desc.FileName, err))
+		}
+	}
+
+	errs = append(errs, validatePriority(manifest)...)
+	return errors.Join(errs...)
+}
+
+// validatePriority checks that priority lists name existing dictionaries once each and
+// that pair overrides only list dictionaries of that pair.
+func validatePriority(manifest *modals.DictionaryManifest) []error {
+	pairs := make(map[int]string, len(manifest.Dictionaries))
+	for _, desc := range manifest.Dictionaries {
+		pairs[desc.Id] = desc.LanguagePair()
+	}
+
+	var errs []error
+	checkList := func(name string, ids []int, pair string) {
+		seen := make(map[int]bool, len(ids))
+		for _, id := range ids {
+			descPair, exists := pairs[id]
+			switch {
+			case !exists:
+				errs = append(errs, fmt.Errorf("priority %s: unknown dictionary %d", name, id))
+			case seen[id]:
+				errs = append(errs, fmt.Errorf("priority %s: dictionary %d listed more than once", name, id))
+			case pair != "" && descPair != pair:
+				errs = append(errs, fmt.Errorf("priority %s: dictionary %d is %s", name, id, descPair))
+			}
+			seen[id] = true
+		}
+	}
+
+	checkList("default", manifest.Priority.Default, "")
+	for _, pair := range slices.Sorted(maps.Keys(manifest.Priority.Pairs)) {
+		ids := manifest.Priority.Pairs[pair]
+		if from, to, ok := strings.Cut(pair, ">"); !ok || from == "" || to == "" {
+			errs = append(errs, fmt.Errorf("priority pair %q: expected From>To, e.g. Ady>Ru", pair))
+			continue
+		}
+		checkList(pair, ids, pair)
+	}
+	return errs
+}
+
+// DictionaryRanks returns the 1-based rank of every manifest dictionary by id.
+// Dictionaries are first ordered by Priority.Default, unlisted ones after the listed
+// ones in id order. Then the dictionaries of each pair in Priority.Pairs are reordered
+// by the pair list within the positions they already hold, so a pair override never
+// moves a dictionary ahead of or behind another pair's.
+func DictionaryRanks(manifest *modals.DictionaryManifest) map[int]int {
+	order := slices.Clone(manifest.Dictionaries)
+	defaultPositions := listPositions(manifest.Priority.Default)
+	slices.SortFunc(order, func(a, b modals.DictionaryDescriptor) int {
+		return cmp.Or(compareListed(defaultPositions, a.Id, b.Id), cmp.Compare(a.Id, b.Id))
+	})
+
+	for pair, ids := range manifest.Priority.Pairs {
+		var slots []int
+		var members []modals.DictionaryDescriptor
+		for i, desc := range order {
+			if desc.LanguagePair() == pair {
+				slots = append(slots, i)
+				members = append(members, desc)
+			}
+		}
+
+		// Stable so members missing from the pair list keep their default order
+		pairPositions := listPositions(ids)
+		slices.SortStableFunc(members, func(a, b modals.DictionaryDescriptor) int {
+			return compareListed(pairPositions, a.Id, b.Id)
+		})
+		for i, slot := range slots {
+			order[slot] = members[i]
+		}
+	}
+
+	ranks := make(map[int]int, len(order))
+	for i, desc := range order {
+		ranks[desc.Id] = i + 1
+	}
+	return ranks
+}
+
+// listPositions maps each id of a priority list to its index.
+func listPositions(ids []int) map[int]int {
+	positions := make(map[int]int, len(ids))
+	for i, id := range ids {
+		positions[id] = i
+	}
+	return positions
+}
+
+// compareListed orders listed ids by position and before unlisted ones. Two
+// unlisted ids compare equal.
+func compareListed(positions map[int]int, a, b int) int {
+	pa, aListed := positions[a]
+	pb, bListed := positions[b]
+	switch {
+	case aListed && bListed:
+		return cmp.Compare(pa, pb)
+	case aListed:
+		return -1
+	case bListed:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// CheckRawFiles reports every dictionary whose raw file is missing from rawDir.
+// It is run before the conversion phase so a missing source fails the whole run
+// up front rather than halfway through.
+func CheckRawFiles(dictionaries []modals.DictionaryDescriptor, rawDir string) error {
+	var errs []error
+	for _, desc := range dictionaries {
+		if _, err := os.Stat(filepath.Join(rawDir, desc.FileName)); err != nil {
+			errs = append(errs, fmt.Errorf("dictionary %d: raw file %s not found in %s", desc.Id, desc.FileName, rawDir))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,109 @@
+package code
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// writeParseReport saves a converter's parse report as <name>.parse-report.json and
+// <name>.parse-report.csv in dir. Issues that were not in the previous report of the
+// same file are printed as regressions.
+func writeParseReport(dir string, report *modals.ParseReport) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create reports directory: %w", err)
+	}
+
+	base := parseReportBase(dir, report.FileName)
+	jsonPath, csvPath := base+".json", base+".csv"
+
+	// Print everything in one call so it is not interleaved with other dictionaries' output
+	var sb strings.Builder
+	if len(report.Issues) > 0 {
+		fmt.Fprintf(&sb, "%d unusable lines in %s (%s), see %s\n", len(report.Issues), report.FileName, formatIssueCounts(report.Counts), jsonPath)
+	}
+	if previous, err := readParseReport(jsonPath); err == nil {
+		added := report.NewIssuesSince(previous)
+		if len(added) > 0 {
+			fmt.Fprintf(&sb, "--%d new unusable lines in %s since the last build:--\n", len(added), report.FileName)
+			for _, issue := range added {
+				fmt.Fprintf(&sb, "line %d (%s): %s\n", issue.Line, issue.Reason, issue.Raw)
+			}
+		}
+	}
+	fmt.Print(sb.String())
+
+	if err := utils.SaveDictToJSON(jsonPath, report); err != nil {
+		return fmt.Errorf("failed to save %s: %w", jsonPath, err)
+	}
+	if err := saveParseReportCSV(csvPath, report); err != nil {
+		return fmt.Errorf("failed to save %s: %w", csvPath, err)
+	}
+	return nil
+}
+
+// parseReportBase returns the path of the parse reports of a raw file, without extension.
+func parseReportBase(dir string, fileName string) string {
+	return filepath.Join(dir, strings.TrimSuffix(fileName, filepath.Ext(fileName))+".parse-report")
+}
+
+// parseReportExists reports whether both parse reports of a raw file are on disk.
+func parseReportExists(dir string, fileName string) bool {
+	base := parseReportBase(dir, fileName)
+	for _, ext := range []string{".json", ".csv"} {
+		if _, err := os.Stat(base + ext); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func readParseReport(path string) (*modals.ParseReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report modals.ParseReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// saveParseReportCSV writes one row per issue: line, reason, detail, raw.
+func saveParseReportCSV(path string, report *modals.ParseReport) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"line", "reason", "detail", "raw"})
+	for _, issue := range report.Issues {
+		w.Write([]string{strconv.Itoa(issue.Line), string(issue.Reason), issue.Detail, issue.Raw})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// formatIssueCounts renders counts per reason, e.g. "empty_line: 3, no_colon: 1".
+func formatIssueCounts(counts map[modals.ParseIssueReason]int) string {
+	reasons := make([]modals.ParseIssueReason, 0, len(counts))
+	for reason := range counts {
+		reasons = append(reasons, reason)
+	}
+	slices.Sort(reasons)
+
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%s: %d", reason, counts[reason])
+	}
+	return strings.Join(parts, ", ")
+}
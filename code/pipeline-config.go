@@ -0,0 +1,135 @@
+package code
+
+import (
+	"fmt"
+	"learn-circassian-helper/modals"
+	"learn-circassian-helper/utils"
+	"path/filepath"
+	"runtime"
+)
+
+// PipelineConfig holds the directories and dictionary selection shared by all phases.
+//
+// The input directory contains the manifest, the backup sources preprocessed by
+// Phase 00 and the raw Phase 01 data; the output
+// directory receives the Phase 02-05 folders. Both default to "content".
+type PipelineConfig struct {
+	InputDir     string
+	OutputDir    string
+	ManifestPath string // defaults to <InputDir>/dictionaries-manifest.json
+	Only         []int  // dictionary ids to convert; empty means all
+	Force        bool   // ignore the build cache and redo every step
+	Jobs         int    // dictionaries processed in parallel by the convert, html and merge steps
+	Verify       bool   // re-read every written phase file and check it round-trips
+	Export       ExportOptions
+}
+
+func DefaultPipelineConfig() PipelineConfig {
+	return PipelineConfig{
+		InputDir:  "content",
+		OutputDir: "content",
+		Jobs:      runtime.NumCPU(),
+	}
+}
+
+// useBuildCache reports whether the convert and html steps may skip dictionaries that
+// the build cache marks as up to date. --verify compares the written files with the
+// data in memory, so like --force it redoes every step.
+func (c PipelineConfig) useBuildCache() bool {
+	return !c.Force && !c.Verify
+}
+
+func (c PipelineConfig) Manifest() string {
+	if c.ManifestPath != "" {
+		return c.ManifestPath
+	}
+	return filepath.Join(c.InputDir, ManifestFileName)
+}
+
+func (c PipelineConfig) BuildCachePath() string {
+	return filepath.Join(c.OutputDir, "build-cache.json")
+}
+
+func (c PipelineConfig) ReportsDir() string {
+	return filepath.Join(c.OutputDir, "reports")
+}
+
+func (c PipelineConfig) BackupDir() string {
+	return filepath.Join(c.InputDir, "backup")
+}
+
+func (c PipelineConfig) RawDir() string {
+	return filepath.Join(c.InputDir, "phase-01-raw-data")
+}
+
+func (c PipelineConfig) JSONDir() string {
+	return filepath.Join(c.OutputDir, "phase-02-json-data")
+}
+
+func (c PipelineConfig) HTMLDir() string {
+	return filepath.Join(c.OutputDir, "phase-03-html-data")
+}
+
+func (c PipelineConfig) MergedDir() string {
+	return filepath.Join(c.OutputDir, "phase-04-merged-database")
+}
+
+func (c PipelineConfig) SQLiteDir() string {
+	return filepath.Join(c.OutputDir, "phase-05-sqlite")
+}
+
+// ExportDir returns the folder an exporter writes the given format to.
+func (c PipelineConfig) ExportDir(format string) string {
+	if c.Export.Dir != "" {
+		return filepath.Join(c.Export.Dir, format)
+	}
+	return filepath.Join(c.OutputDir, "exports", format)
+}
+
+// SelectDictionaries returns the manifest entries chosen by Only, in manifest order.
+// Ids that do not appear in the manifest are an error.
+func (c PipelineConfig) SelectDictionaries(manifest *modals.DictionaryManifest) ([]modals.DictionaryDescriptor, error) {
+	if len(c.Only) == 0 {
+		return manifest.Dictionaries, nil
+	}
+
+	wanted := make(map[int]bool, len(c.Only))
+	for _, id := range c.Only {
+		wanted[id] = true
+	}
+
+	selected := make([]modals.DictionaryDescriptor, 0, len(c.Only))
+	for _, desc := range manifest.Dictionaries {
+		if wanted[desc.Id] {
+			selected = append(selected, desc)
+			delete(wanted, desc.Id)
+		}
+	}
+
+	for _, id := range c.Only {
+		if wanted[id] {
+			return nil, fmt.Errorf("dictionary %d is not in the manifest", id)
+		}
+	}
+	return selected, nil
+}
+
+// PipelineRun bundles the state shared by the steps of one pipeline invocation.
+type PipelineRun struct {
+	Config   PipelineConfig
+	Manifest *modals.DictionaryManifest
+	Summary  *PipelineSummary
+	Cache    *BuildCache
+}
+
+// savePhaseFile writes a Phase 02-04 JSON file and, in verify mode, checks that it
+// decodes back to the same value.
+func savePhaseFile(config PipelineConfig, filePath string, data interface{}) error {
+	if err := utils.SaveDictToJSON(filePath, data); err != nil {
+		return err
+	}
+	if config.Verify {
+		return utils.VerifyJSONFile(filePath, data)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package code
+
+import (
+	"fmt"
+	"io"
+	"learn-circassian-helper/modals"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+type DictStatus string
+
+const (
+	DictStatusSucceeded DictStatus = "succeeded"
+	DictStatusFailed    DictStatus = "failed"
+	DictStatusSkipped   DictStatus = "skipped"
+)
+
+// DictionaryResult is the outcome of a pipeline run for one dictionary. Step and
+// Cause describe the first step that failed or skipped it.
+type DictionaryResult struct {
+	Id          int
+	FileName    string
+	Status      DictStatus
+	Step        string
+	Cause       string
+	CachedSteps []string // steps skipped because their output was up to date
+}
+
+// PipelineSummary records what happened to every dictionary during a run so the
+// pipeline can keep going after a failure and report everything at the end.
+// It is safe for concurrent use by the workers of a step.
+type PipelineSummary struct {
+	mu         sync.Mutex
+	failFast   bool
+	results    map[int]*DictionaryResult
+	stepErrors []string
+}
+
+// NewPipelineSummary creates an empty summary. With failFast set, the first
+// recorded failure is returned to the caller so the run stops immediately.
+func NewPipelineSummary(failFast bool) *PipelineSummary {
+	return &PipelineSummary{
+		failFast: failFast,
+		results:  make(map[int]*DictionaryResult),
+	}
+}
+
+// Succeeded marks a step as done for a dictionary. An earlier failure or skip is kept.
+func (s *PipelineSummary) Succeeded(desc modals.DictionaryDescriptor, step string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.succeeded(desc, step)
+}
+
+func (s *PipelineSummary) succeeded(desc modals.DictionaryDescriptor, step string) {
+	if _, exists := s.results[desc.Id]; exists {
+		return
+	}
+	s.results[desc.Id] = &DictionaryResult{Id: desc.Id, FileName: desc.FileName, Status: DictStatusSucceeded, Step: step}
+}
+
+// Cached marks a step as done for a dictionary without any work, because the
+// build cache found its output up to date.
+func (s *PipelineSummary) Cached(desc modals.DictionaryDescriptor, step string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.succeeded(desc, step)
+	result := s.results[desc.Id]
+	result.CachedSteps = append(result.CachedSteps, step)
+}
+
+// Fail records a failed step for a dictionary. It returns the error when the run
+// is in fail-fast mode and should stop, nil otherwise.
+func (s *PipelineSummary) Fail(desc modals.DictionaryDescriptor, step string, err error) error {
+	fmt.Printf("Error in %s for %s: %v\n", step, desc.FileName, err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(desc, DictStatusFailed, step, err.Error())
+
+	if s.failFast {
+		return fmt.Errorf("%s: %w", desc.FileName, err)
+	}
+	return nil
+}
+
+// Skip records that a step did not run for a dictionary, and why.
+func (s *PipelineSummary) Skip(desc modals.DictionaryDescriptor, step string, reason string) {
+	fmt.Printf("Skipping %s in %s: %s\n", desc.FileName, step, reason)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(desc, DictStatusSkipped, step, reason)
+}
+
+// StepFailed records an error that stopped a whole step, such as an unwritable output directory.
+func (s *PipelineSummary) StepFailed(step string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stepErrors = append(s.stepErrors, fmt.Sprintf("%s: %v", step, err))
+}
+
+// HasFailed reports whether an earlier step failed for the given dictionary.
+func (s *PipelineSummary) HasFailed(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, exists := s.results[id]
+	return exists && result.Status == DictStatusFailed
+}
+
+// Result returns what happened to a dictionary so far in this run, if anything.
+func (s *PipelineSummary) Result(id int) (DictionaryResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result, exists := s.results[id]
+	if !exists {
+		return DictionaryResult{}, false
+	}
+	return *result, true
+}
+
+// HasFailures reports whether any dictionary or step failed.
+func (s *PipelineSummary) HasFailures() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.stepErrors) > 0 {
+		return true
+	}
+	for _, result := range s.results {
+		if result.Status == DictStatusFailed {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *PipelineSummary) record(desc modals.DictionaryDescriptor, status DictStatus, step string, cause string) {
+	// Keep the first problem: later steps usually fail because of it
+	if existing, exists := s.results[desc.Id]; exists && existing.Status != DictStatusSucceeded {
+		return
+	}
+	s.results[desc.Id] = &DictionaryResult{Id: desc.Id, FileName: desc.FileName, Status: status, Step: step, Cause: cause}
+}
+
+// Print writes the summary table, ordered by dictionary id.
+func (s *PipelineSummary) Print(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int, 0, len(s.results))
+	for id := range s.results {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	counts := make(map[DictStatus]int)
+	fmt.Fprintln(w, "\nSummary:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDictionary\tStatus\tStep\tCause")
+	for _, id := range ids {
+		result := s.results[id]
+		counts[result.Status]++
+		step, cause := result.Step, result.Cause
+		if result.Status == DictStatusSucceeded {
+			step = ""
+		}
+		if cause == "" && len(result.CachedSteps) > 0 {
+			cause = "up to date: " + strings.Join(result.CachedSteps, ", ")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", result.Id, result.FileName, result.Status, step, cause)
+	}
+	tw.Flush()
+
+	for _, stepErr := range s.stepErrors {
+		fmt.Fprintf(w, "Step failed: %s\n", stepErr)
+	}
+	fmt.Fprintf(w, "%d succeeded, %d failed, %d skipped\n",
+		counts[DictStatusSucceeded], counts[DictStatusFailed], counts[DictStatusSkipped])
+}
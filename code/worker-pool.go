@@ -0,0 +1,53 @@
+package code
+
+import (
+	"learn-circassian-helper/modals"
+	"sync"
+)
+
+// forEachDictionary calls fn for every dictionary on up to jobs goroutines. i is the
+// dictionary's position in the slice, so callers can store results in order. Once fn
+// returns an error no new dictionaries are started; the first error is returned after
+// the running ones finish.
+func forEachDictionary(jobs int, dictionaries []modals.DictionaryDescriptor, fn func(i int, desc modals.DictionaryDescriptor) error) error {
+	jobs = max(1, min(jobs, len(dictionaries)))
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	stopped := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+
+	work := make(chan int)
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				if err := fn(i, dictionaries[i]); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := range dictionaries {
+		if stopped() {
+			break
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	return firstErr
+}
@@ -1,10 +1,353 @@
 package main
 
-import "learn-circassian-helper/code"
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"learn-circassian-helper/code"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Exit codes, so the pipeline can be scripted.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// step is one transition of the pipeline, from phase N to phase N+1.
+type step struct {
+	command     string
+	fromPhase   int
+	description string
+	usesOnly    bool // whether --only restricts the step to selected dictionaries
+	export      bool // an export of the Phase 04 data, never run by "all"
+	flags       func(fs *flag.FlagSet, config *code.PipelineConfig)
+	run         func(run *code.PipelineRun) error
+}
+
+var steps = []step{
+	{
+		command:     "preprocess",
+		fromPhase:   0,
+		description: "Phase 00 → 01: apply the manifest's rewrite rules to content/backup files",
+		usesOnly:    true,
+		run:         code.CallConvertPhase00ToPhase01,
+	},
+	{
+		command:     "convert",
+		fromPhase:   1,
+		description: "Phase 01 → 02: parse raw dictionaries into standardized JSON",
+		usesOnly:    true,
+		run:         code.CallConvertPhase01ToPhase02,
+	},
+	{
+		command:     "html",
+		fromPhase:   2,
+		description: "Phase 02 → 03: render definitions as HTML",
+		usesOnly:    true,
+		run:         code.CallConvertPhase02ToPhase03,
+	},
+	{
+		command:     "merge",
+		fromPhase:   3,
+		description: "Phase 03 → 04: merge all dictionaries into one database",
+		run:         code.CallConvertPhase03ToPhase04,
+	},
+	{
+		command:     "sqlite",
+		fromPhase:   4,
+		description: "Phase 04 → 05: write the merged database to SQLite",
+		run:         code.CallConvertPhase04ToPhase05,
+	},
+}
+
+// exports write the Phase 04 data in the formats of other dictionary software.
+var exports = []step{
+	{
+		command:     "stardict",
+		fromPhase:   4,
+		description: "Phase 04 → StarDict (.ifo/.idx/.dict.dz/.syn) for GoldenDict and KOReader",
+		usesOnly:    true,
+		export:      true,
+		flags: func(fs *flag.FlagSet, config *code.PipelineConfig) {
+			fs.BoolVar(&config.Export.Combined, "combined", false, "write one dictionary holding every selected source instead of one per source")
+		},
+		run: code.CallExportStarDict,
+	},
+	{
+		command:     "kindle",
+		fromPhase:   4,
+		description: "Phase 04 → Kindle dictionary source (OPF + XHTML) per language pair, for kindlegen",
+		usesOnly:    true,
+		export:      true,
+		run:         code.CallExportKindle,
+	},
+	{
+		command:     "yomitan",
+		fromPhase:   4,
+		description: "Phase 02/03 → Yomitan dictionary ZIPs for pop-up lookups in the browser",
+		usesOnly:    true,
+		export:      true,
+		run:         code.CallExportYomitan,
+	},
+	{
+		command:     "xdxf",
+		fromPhase:   4,
+		description: "Phase 02/03 → XDXF dictionaries (import XDXF with the \"xdxf\" converter)",
+		usesOnly:    true,
+		export:      true,
+		run:         code.CallExportXDXF,
+	},
+	{
+		command:     "tei",
+		fromPhase:   4,
+		description: "Phase 02/03 → TEI Lex-0 XML for archiving and comparison",
+		usesOnly:    true,
+		export:      true,
+		run:         code.CallExportTEI,
+	},
+	{
+		command:     "dsl",
+		fromPhase:   4,
+		description: "Phase 02/03 → ABBYY Lingvo DSL (UTF-16LE) for Lingvo and GoldenDict",
+		usesOnly:    true,
+		export:      true,
+		flags: func(fs *flag.FlagSet, config *code.PipelineConfig) {
+			fs.BoolVar(&config.Export.Abbreviations, "abbreviations", false, "also write a <name>_abrv.dsl file explaining the part-of-speech labels")
+		},
+		run: code.CallExportDSL,
+	},
+	{
+		command:     "apple",
+		fromPhase:   4,
+		description: "Phase 04 → Apple Dictionary Development Kit source (XML, CSS, plist) per language pair",
+		usesOnly:    true,
+		export:      true,
+		run:         code.CallExportApple,
+	},
+	{
+		command:     "anki",
+		fromPhase:   4,
+		description: "Phase 02/03 → Anki decks (.apkg) with word → gloss and example cloze notes",
+		usesOnly:    true,
+		export:      true,
+		flags: func(fs *flag.FlagSet, config *code.PipelineConfig) {
+			fs.StringVar(&config.Export.WordList, "words", "", "file of the words to put in the decks, one per line (default: every word)")
+		},
+		run: code.CallExportAnki,
+	},
+	{
+		command:     "site",
+		fromPhase:   4,
+		description: "Phase 04 → static website with headword pages, letter indexes and a JSON search index",
+		usesOnly:    true,
+		export:      true,
+		run:         code.CallExportSite,
+	},
+	{
+		command:     "jsonl",
+		fromPhase:   4,
+		description: "Phase 04 → one JSON Lines record per headword and dictionary, with a JSON Schema",
+		usesOnly:    true,
+		export:      true,
+		flags: func(fs *flag.FlagSet, config *code.PipelineConfig) {
+			fs.BoolVar(&config.Export.Gzip, "gzip", false, "write entries.jsonl.gz instead of entries.jsonl")
+		},
+		run: code.CallExportJSONLines,
+	},
+}
 
 func main() {
-	code.CallConvertPhase01ToPhase02()
-	code.CallConvertPhase02ToPhase03()
-	code.CallConvertPhase03ToPhase04()
-	code.CallConvertPhase04ToPhase05()
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	// Running without a command keeps the old behaviour of executing phases 1-5
+	command := "all"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	if command == "help" {
+		printUsage()
+		return exitOK
+	}
+
+	selected, ok := stepsForCommand(command)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", command)
+		printUsage()
+		return exitUsage
+	}
+
+	config := code.DefaultPipelineConfig()
+	fromPhase := 1
+	failFast := false
+
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.StringVar(&config.InputDir, "input-dir", config.InputDir, "directory holding the manifest and phase-01-raw-data")
+	fs.StringVar(&config.OutputDir, "output-dir", config.OutputDir, "directory receiving the phase-02 to phase-05 folders")
+	fs.StringVar(&config.ManifestPath, "manifest", "", "manifest path (default <input-dir>/"+code.ManifestFileName+")")
+	fs.BoolVar(&failFast, "fail-fast", false, "stop at the first failing dictionary instead of continuing with the others")
+	fs.IntVar(&config.Jobs, "jobs", config.Jobs, "number of dictionaries processed in parallel")
+	fs.BoolVar(&config.Verify, "verify", false, "re-read every written phase file and check that it decodes to the same data")
+	if selected[0].usesOnly && !selected[0].export {
+		fs.BoolVar(&config.Force, "force", false, "ignore the build cache and convert every selected dictionary again")
+	}
+	if selected[0].usesOnly {
+		fs.Var((*intListFlag)(&config.Only), "only", "comma-separated dictionary ids to process, e.g. 30,33 (merge and sqlite always use all)")
+	}
+	if selected[0].export {
+		fs.StringVar(&config.Export.Dir, "export-dir", "", "directory receiving the export folders (default <output-dir>/exports)")
+		fs.Var((*stringListFlag)(&config.Export.Pairs), "pair", "comma-separated language pairs to export, e.g. Kbd-Ru (or \"Kbd>Ru\")")
+	}
+	if selected[0].flags != nil {
+		selected[0].flags(fs, &config)
+	}
+	if command == "all" {
+		fs.IntVar(&fromPhase, "from-phase", fromPhase, "skip steps reading from earlier phases (0=preprocess, 1=convert, 2=html, 3=merge, 4=sqlite)")
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return exitUsage
+	}
+	if config.Jobs < 1 {
+		fmt.Fprintln(os.Stderr, "--jobs must be at least 1")
+		return exitUsage
+	}
+	lastPhase := steps[len(steps)-1].fromPhase
+	if fromPhase < 0 || fromPhase > lastPhase {
+		fmt.Fprintf(os.Stderr, "--from-phase must be between 0 and %d\n", lastPhase)
+		return exitUsage
+	}
+	if command == "all" {
+		selected = selected[:0:0]
+		for _, s := range steps {
+			if s.fromPhase >= fromPhase {
+				selected = append(selected, s)
+			}
+		}
+	}
+
+	// Validate the manifest up front so a bad entry fails before any phase runs
+	manifest, err := code.LoadManifest(config.Manifest())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	dictionaries, err := config.SelectDictionaries(manifest)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if selected[0].command == "convert" {
+		if err := code.CheckRawFiles(dictionaries, config.RawDir()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+	}
+
+	pipelineRun := &code.PipelineRun{
+		Config:   config,
+		Manifest: manifest,
+		Summary:  code.NewPipelineSummary(failFast),
+		Cache:    code.LoadBuildCache(config.BuildCachePath()),
+	}
+	for _, s := range selected {
+		// A step-level error leaves later phases without valid input, so stop there
+		if err := s.run(pipelineRun); err != nil {
+			fmt.Fprintf(os.Stderr, "%s failed: %v\n", s.command, err)
+			pipelineRun.Summary.StepFailed(s.command, err)
+			break
+		}
+	}
+
+	pipelineRun.Summary.Print(os.Stdout)
+	if pipelineRun.Summary.HasFailures() {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// stepsForCommand returns the steps a command runs, in order.
+func stepsForCommand(command string) ([]step, bool) {
+	if command == "all" {
+		return steps, true
+	}
+	for i, s := range steps {
+		if s.command == command {
+			return steps[i : i+1], true
+		}
+	}
+	for i, s := range exports {
+		if s.command == command {
+			return exports[i : i+1], true
+		}
+	}
+	return nil, false
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: learn-circassian-helper [command] [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, s := range steps {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", s.command, s.description)
+	}
+	fmt.Fprintf(os.Stderr, "  %-10s %s\n", "all", "run every step from convert on, in order (default)")
+	fmt.Fprintln(os.Stderr, "\nExports (read Phase 04, write to <output-dir>/exports/<command>):")
+	for _, s := range exports {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", s.command, s.description)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'learn-circassian-helper <command> -h' for the flags of a command.")
+}
+
+// intListFlag parses a comma-separated list of integers, e.g. "30,33".
+type intListFlag []int
+
+func (f *intListFlag) String() string {
+	parts := make([]string, len(*f))
+	for i, v := range *f {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *intListFlag) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return fmt.Errorf("invalid dictionary id %q", part)
+		}
+		*f = append(*f, v)
+	}
+	return nil
+}
+
+// stringListFlag parses a comma-separated list of strings, e.g. "Kbd-Ru,Ady-Ru".
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*f = append(*f, part)
+		}
+	}
+	return nil
 }
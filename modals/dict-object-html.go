@@ -23,9 +23,19 @@ type MergedDictEntry struct {
 	Html string `json:"html"`
 }
 
+// MergedWord is one line of the Phase 04 merged-database.jsonl file: a headword
+// with its entries from every dictionary, in DictionaryInfo.Rank order.
+type MergedWord struct {
+	Word    string            `json:"word"`
+	Entries []MergedDictEntry `json:"entries"`
+}
+
+// DictionaryInfo is the metadata of a merged dictionary, stored once instead of in
+// every entry.
 type DictionaryInfo struct {
 	Id       int    `json:"id"`
 	Title    string `json:"title"`
 	FromLang string `json:"from_lang"`
 	ToLang   string `json:"to_lang"`
+	Rank     int    `json:"rank"` // 1 for the dictionary whose entries come first
 }
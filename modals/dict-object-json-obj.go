@@ -60,6 +60,14 @@ func NewWordObject(wordType string) *WordObject {
 
 // --- Helper Methods ---
 
+func (d *DictObjectJsonObj) Info() DictionaryInfo {
+	return DictionaryInfo{Id: d.Id, Title: d.Title, FromLang: d.FromLang, ToLang: d.ToLang}
+}
+
+func (d *DictObjectJsonObj) WordCount() int {
+	return len(d.WordsToJsonObjMap)
+}
+
 func (w *WordObject) AddCognate(language, spelling string) {
 	w.Cognates = append(w.Cognates, Cognate{
 		Word:    spelling,
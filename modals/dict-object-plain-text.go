@@ -1,5 +1,7 @@
 package modals
 
+import "fmt"
+
 type DictFormat int
 
 // 2. Declare the constants using iota
@@ -10,6 +12,21 @@ const (
 	DictFormatPlain                     // 3
 )
 
+// ParseDictFormat maps the manifest spelling of a format ("html", "json", "plain")
+// to its DictFormat value.
+func ParseDictFormat(name string) (DictFormat, error) {
+	switch name {
+	case "html":
+		return DictFormatHTML, nil
+	case "json":
+		return DictFormatJSON, nil
+	case "plain":
+		return DictFormatPlain, nil
+	default:
+		return DictFormatUnknown, fmt.Errorf("unknown dictionary format %q", name)
+	}
+}
+
 type DictObjectPlainText struct {
 	Title               string              `json:"title"`
 	Id                  int                 `json:"id"`
@@ -29,3 +46,11 @@ func NewDictObjectPlainText(title string, id int, fromLang string, toLang string
 		Format:              format,
 	}
 }
+
+func (d *DictObjectPlainText) Info() DictionaryInfo {
+	return DictionaryInfo{Id: d.Id, Title: d.Title, FromLang: d.FromLang, ToLang: d.ToLang}
+}
+
+func (d *DictObjectPlainText) WordCount() int {
+	return len(d.WordsToPlainTextMap)
+}
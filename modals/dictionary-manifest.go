@@ -0,0 +1,37 @@
+package modals
+
+// DictionaryDescriptor describes a single raw source dictionary: where its
+// Phase 01 file lives, how it is labelled and which converter parses it.
+type DictionaryDescriptor struct {
+	Id        int    `json:"id"`
+	FileName  string `json:"file_name"`
+	Title     string `json:"title"`
+	FromLang  string `json:"from_lang"`
+	ToLang    string `json:"to_lang"`
+	Format    string `json:"format"` // "html", "json" or "plain"
+	Converter string `json:"converter"`
+
+	// Preprocess lists the rewrite rules that turn content/backup/<FileName> into
+	// the Phase 01 raw file. Dictionaries without rules are used as they are.
+	Preprocess []RewriteRule `json:"preprocess,omitempty"`
+}
+
+// LanguagePair returns the "From>To" key used by DictionaryPriority.Pairs, e.g. "Ady>Ru".
+func (d DictionaryDescriptor) LanguagePair() string {
+	return d.FromLang + ">" + d.ToLang
+}
+
+// DictionaryPriority orders the entries of a merged word. Default lists dictionary
+// ids from most to least important; ids not listed follow in id order. Pairs
+// reorders the dictionaries of one language pair among themselves, keyed by
+// LanguagePair, without moving them relative to other pairs.
+type DictionaryPriority struct {
+	Default []int            `json:"default,omitempty"`
+	Pairs   map[string][]int `json:"pairs,omitempty"`
+}
+
+// DictionaryManifest is the list of all source dictionaries fed into the pipeline.
+type DictionaryManifest struct {
+	Dictionaries []DictionaryDescriptor `json:"dictionaries"`
+	Priority     DictionaryPriority     `json:"priority"`
+}
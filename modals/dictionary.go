@@ -0,0 +1,8 @@
+package modals
+
+// Dictionary is a parsed Phase 02 dictionary object, as produced by a converter.
+// It is implemented by *DictObjectPlainText and *DictObjectJsonObj.
+type Dictionary interface {
+	Info() DictionaryInfo
+	WordCount() int
+}
@@ -0,0 +1,17 @@
+package modals
+
+// EntryRecord is one line of the JSON Lines export: the entry of one dictionary for
+// one headword, flattened for data analysis. The description tags document the
+// fields in the generated JSON Schema.
+type EntryRecord struct {
+	Word         string      `json:"word" description:"Headword as stored in the pipeline, palochka written as 1"`
+	DisplayWord  string      `json:"display_word" description:"Headword with palochka written as Ӏ"`
+	DictionaryId int         `json:"dictionary_id" description:"Manifest id of the dictionary"`
+	Dictionary   string      `json:"dictionary" description:"Title of the dictionary"`
+	FromLang     string      `json:"from_lang" description:"Language of the headwords, e.g. Kbd"`
+	ToLang       string      `json:"to_lang" description:"Language of the definitions, e.g. Ru"`
+	Format       string      `json:"format" description:"Format of the dictionary source: html, json or plain"`
+	Text         string      `json:"text" description:"Plain-text definition, one line per block of the HTML"`
+	Html         string      `json:"html" description:"Definition as rendered in Phase 03"`
+	Structured   *WordObject `json:"structured,omitempty" description:"Parsed entry of dictionaries in the json format"`
+}
@@ -0,0 +1,66 @@
+package modals
+
+// ParseIssueReason is a stable code describing why a converter could not use a line.
+type ParseIssueReason string
+
+const (
+	ParseIssueNoJSONObject   ParseIssueReason = "no_json_object"   // value does not contain a {...} object
+	ParseIssueJSONParseError ParseIssueReason = "json_parse_error" // value is not valid JSON
+	ParseIssueEmptyLine      ParseIssueReason = "empty_line"       // line without any words
+	ParseIssueSectionHeader  ParseIssueReason = "section_header"   // alphabet section header such as "A-B"
+	ParseIssueEmptyArticle   ParseIssueReason = "empty_article"    // XDXF article without a key or content
+)
+
+// ParseIssue is one line a converter could not use. Line is 1-based.
+type ParseIssue struct {
+	Line   int              `json:"line"`
+	Reason ParseIssueReason `json:"reason"`
+	Raw    string           `json:"raw"`
+	Detail string           `json:"detail,omitempty"`
+}
+
+// ParseReport collects the lines a converter could not use while parsing a raw dictionary.
+type ParseReport struct {
+	FileName string                   `json:"file_name"`
+	Counts   map[ParseIssueReason]int `json:"counts"`
+	Issues   []ParseIssue             `json:"issues"`
+}
+
+func NewParseReport(fileName string) *ParseReport {
+	return &ParseReport{
+		FileName: fileName,
+		Counts:   make(map[ParseIssueReason]int),
+		Issues:   make([]ParseIssue, 0),
+	}
+}
+
+// AddIssue records an unusable line. line is 1-based.
+func (r *ParseReport) AddIssue(line int, reason ParseIssueReason, raw string, detail string) {
+	r.Issues = append(r.Issues, ParseIssue{Line: line, Reason: reason, Raw: raw, Detail: detail})
+	r.Counts[reason]++
+}
+
+// NewIssuesSince returns the issues that are not in a previous report of the same file.
+// Issues are matched by reason and raw line rather than line number, so editing an
+// earlier part of the file does not make every later issue look new.
+func (r *ParseReport) NewIssuesSince(previous *ParseReport) []ParseIssue {
+	type issueKey struct {
+		reason ParseIssueReason
+		raw    string
+	}
+	known := make(map[issueKey]int)
+	for _, issue := range previous.Issues {
+		known[issueKey{issue.Reason, issue.Raw}]++
+	}
+
+	added := make([]ParseIssue, 0)
+	for _, issue := range r.Issues {
+		key := issueKey{issue.Reason, issue.Raw}
+		if known[key] > 0 {
+			known[key]--
+			continue
+		}
+		added = append(added, issue)
+	}
+	return added
+}
@@ -0,0 +1,24 @@
+package modals
+
+const (
+	RewriteKindRegex    = "regex"
+	RewriteKindHeadword = "headword"
+)
+
+// RewriteRule is one fix applied to a dictionary's backup file by the preprocess
+// step (Phase 00) before it becomes the Phase 01 raw file.
+//
+// A "regex" rule replaces every match of Pattern with Replacement (Go regexp
+// syntax, e.g. " ${0}"). Go regexps have no lookbehind, so PrecededBy optionally
+// restricts matches to those whose preceding character matches a character class.
+//
+// A "headword" rule splits each line at the first match of Pattern and, when the
+// part before it is not blank, removes OCR spaces from that headword while keeping
+// a space before a trailing homonym number ("А Б А 1." → "АБА 1.").
+type RewriteRule struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind,omitempty"` // "regex" (default) or "headword"
+	Pattern     string `json:"pattern"`
+	PrecededBy  string `json:"preceded_by,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// dictzipChunkSize is the uncompressed size of one dictzip chunk. It is the value
+// used by the dictzip tool, small enough that a compressed chunk always fits the
+// 16-bit size fields of the header.
+const dictzipChunkSize = 58315
+
+// maxDictzipChunks is the most chunks whose sizes fit in the 16-bit extra field length.
+const maxDictzipChunks = (0xFFFF - 10) / 2
+
+// DictzipFile compresses srcPath into distPath in dictzip format: a gzip file whose
+// "RA" extra field lists independently compressed chunks, so dictionary readers can
+// decompress a single entry without inflating the whole file. The gzip header carries
+// no timestamp or file name, so identical input gives identical output.
+func DictzipFile(srcPath, distPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("open file error: %w", err)
+	}
+	defer src.Close()
+
+	// Every chunk starts a fresh deflate writer, so no back-reference crosses a chunk
+	// boundary, and ends with a flush, so it ends on a byte boundary
+	var compressed bytes.Buffer
+	var chunkSizes []uint16
+	crc := crc32.NewIEEE()
+	totalSize := uint32(0)
+	chunk := make([]byte, dictzipChunkSize)
+	for {
+		n, err := io.ReadFull(src, chunk)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("read file error: %w", err)
+		}
+		if len(chunkSizes) == maxDictzipChunks {
+			return fmt.Errorf("%s is too large for dictzip", srcPath)
+		}
+
+		start := compressed.Len()
+		fw, _ := flate.NewWriter(&compressed, flate.BestCompression) // only fails on an invalid level
+		fw.Write(chunk[:n])
+		if err := fw.Flush(); err != nil {
+			return fmt.Errorf("compress error: %w", err)
+		}
+		chunkSizes = append(chunkSizes, uint16(compressed.Len()-start))
+		crc.Write(chunk[:n])
+		totalSize += uint32(n)
+	}
+
+	// An empty final block terminates the deflate stream; it belongs to the last chunk
+	start := compressed.Len()
+	fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	if err := fw.Close(); err != nil {
+		return fmt.Errorf("compress error: %w", err)
+	}
+	if len(chunkSizes) == 0 {
+		chunkSizes = append(chunkSizes, 0)
+	}
+	chunkSizes[len(chunkSizes)-1] += uint16(compressed.Len() - start)
+
+	var header bytes.Buffer
+	header.Write([]byte{0x1f, 0x8b, 8, 0x04}) // gzip magic, deflate, FEXTRA
+	header.Write([]byte{0, 0, 0, 0})          // no modification time
+	header.Write([]byte{2, 3})                // maximum compression, Unix
+	raLen := 6 + 2*len(chunkSizes)
+	binary.Write(&header, binary.LittleEndian, uint16(4+raLen)) // XLEN
+	header.Write([]byte{'R', 'A'})
+	binary.Write(&header, binary.LittleEndian, uint16(raLen))
+	binary.Write(&header, binary.LittleEndian, uint16(1)) // version
+	binary.Write(&header, binary.LittleEndian, uint16(dictzipChunkSize))
+	binary.Write(&header, binary.LittleEndian, uint16(len(chunkSizes)))
+	binary.Write(&header, binary.LittleEndian, chunkSizes)
+
+	var trailer bytes.Buffer
+	binary.Write(&trailer, binary.LittleEndian, crc.Sum32())
+	binary.Write(&trailer, binary.LittleEndian, totalSize)
+
+	out := append(header.Bytes(), compressed.Bytes()...)
+	out = append(out, trailer.Bytes()...)
+	if err := os.WriteFile(distPath, out, 0644); err != nil {
+		return fmt.Errorf("write file error: %w", err)
+	}
+	return nil
+}
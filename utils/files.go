@@ -2,29 +2,34 @@ package utils
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"os"
-	"strings"
 )
 
 // ReadFileLineByLine reads a file and executes a callback for each line.
 // If the callback returns an error, processing stops and that error is returned.
-func ReadFileLineByLine(filePath string, callback func(line string, index int) error) error {
+func ReadFileLineByLine(filePath string, callback func(line string, index int) error) (err error) {
 	f, err := os.Open(filePath)
 	if err != nil {
 		return err
 	}
 	// Ensure file is closed even if an error occurs
 	defer func(f *os.File) {
-		err := f.Close()
-		if err != nil {
-			log.Fatalf("close file error: %v", err)
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("close file error: %w", closeErr)
 		}
 	}(f)
 
-	sc := bufio.NewScanner(f)
+	return ReadLineByLine(f, callback)
+}
+
+// ReadLineByLine executes a callback for each line read from r.
+// If the callback returns an error, processing stops and that error is returned.
+func ReadLineByLine(r io.Reader, callback func(line string, index int) error) error {
+	sc := bufio.NewScanner(r)
 
 	// Increase buffer to 1MB to handle potential long dictionary entries
 	const maxCapacity = 1024 * 1024
@@ -42,39 +47,31 @@ func ReadFileLineByLine(filePath string, callback func(line string, index int) e
 	return sc.Err() // Return scanner errors (like file read issues)
 }
 
-// SaveDictToJSON marshals the object to JSON, applies specific string replacements
-// (un-escaping HTML and swapping \" for '), and writes to the specific file path.
-func SaveDictToJSON(filePath string, data interface{}) error {
-	// 1. Marshal with indentation
-	bytes, err := json.MarshalIndent(data, "", "\t")
-	if err != nil {
+// SaveDictToJSON writes the object as tab-indented JSON. HTML characters are not
+// escaped, so definitions stay readable, but the output is otherwise standard JSON
+// and decodes back to exactly the same value.
+func SaveDictToJSON(filePath string, data interface{}) (err error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(data); err != nil {
 		return fmt.Errorf("json marshal error: %w", err)
 	}
 
-	// 2. Convert to string to perform replacements
-	jsonStr := string(bytes)
-
-	// 3. Apply the specific replacements from your old repo logic
-	// specific to keeping HTML readable and cleaning up quotes
-	jsonStr = strings.ReplaceAll(jsonStr, "\\u003c", "<")
-	jsonStr = strings.ReplaceAll(jsonStr, "\\u003e", ">")
-	jsonStr = strings.ReplaceAll(jsonStr, "\\u0026", "&") // Good practice to unescape ampersands too
-	jsonStr = strings.ReplaceAll(jsonStr, "\\\"", "'")
-
-	// 4. Create and Write to file
 	f, err := os.Create(filePath)
 	if err != nil {
 		return fmt.Errorf("create file error: %w", err)
 	}
+	// A failed close can mean the data never reached the disk, so it is reported too
 	defer func(f *os.File) {
-		err := f.Close()
-		if err != nil {
-			log.Fatalf("close file error: %v", err)
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("close file error: %w", closeErr)
 		}
 	}(f)
 
-	_, err = f.WriteString(jsonStr)
-	if err != nil {
+	// Encode ends the document with a newline, which the phase files never had
+	if _, err := f.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))); err != nil {
 		return fmt.Errorf("write file error: %w", err)
 	}
 
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// lineTrackingReader records the offset of every newline that passes through it,
+// so byte offsets reported by a json.Decoder can be turned into line numbers.
+type lineTrackingReader struct {
+	r        io.Reader
+	offset   int64
+	newlines []int64
+}
+
+func (lr *lineTrackingReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			lr.newlines = append(lr.newlines, lr.offset+int64(i))
+		}
+	}
+	lr.offset += int64(n)
+	return n, err
+}
+
+// lineAt returns the 1-based line holding the byte at offset.
+func (lr *lineTrackingReader) lineAt(offset int64) int {
+	return sort.Search(len(lr.newlines), func(i int) bool { return lr.newlines[i] >= offset }) + 1
+}
+
+// ReadJSONObject streams the top-level JSON object in r and calls callback for each
+// key/value pair in document order, with the value still encoded and the 1-based line
+// of its key. Layout does not matter: entries may be pretty-printed, span several
+// lines or share one, and keys may repeat. Syntax errors stop the stream and are
+// returned with the line they occur on.
+func ReadJSONObject(r io.Reader, callback func(key string, value json.RawMessage, line int) error) error {
+	lr := &lineTrackingReader{r: r}
+	dec := json.NewDecoder(lr)
+
+	// wrapErr adds the line number to decoder errors
+	wrapErr := func(err error) error {
+		offset := dec.InputOffset()
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		}
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("line %d: %w", lr.lineAt(offset), err)
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return wrapErr(err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("line %d: expected a JSON object, found %v", lr.lineAt(dec.InputOffset()), tok)
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return wrapErr(err)
+		}
+		key := tok.(string) // object keys are always strings
+		line := lr.lineAt(dec.InputOffset())
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return wrapErr(err)
+		}
+		if err := callback(key, value, line); err != nil {
+			return err // Stop and propagate error from the callback
+		}
+	}
+
+	// Consume the closing brace and make sure nothing follows the object
+	if _, err := dec.Token(); err != nil {
+		return wrapErr(err)
+	}
+	if tok, err := dec.Token(); err != io.EOF {
+		if err != nil {
+			return wrapErr(err)
+		}
+		return fmt.Errorf("line %d: unexpected %v after the JSON object", lr.lineAt(dec.InputOffset()), tok)
+	}
+	return nil
+}
+
+// VerifyJSONFile re-reads a file written by SaveDictToJSON and checks that it decodes
+// to a value identical to data, i.e. that writing it lost nothing.
+func VerifyJSONFile(filePath string, data interface{}) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("verify %s: %w", filePath, err)
+	}
+	if err := verifyJSONBytes(content, data); err != nil {
+		return fmt.Errorf("verify %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// verifyJSONBytes checks that content decodes to a value identical to data.
+func verifyJSONBytes(content []byte, data interface{}) error {
+	want := reflect.ValueOf(data)
+	for want.Kind() == reflect.Pointer && !want.IsNil() {
+		want = want.Elem()
+	}
+	got := reflect.New(want.Type())
+	if err := json.Unmarshal(content, got.Interface()); err != nil {
+		return err
+	}
+	if !equalJSONValues(got.Elem(), want) {
+		return errors.New("the JSON does not decode to the value that was written")
+	}
+	return nil
+}
+
+// equalJSONValues is reflect.DeepEqual restricted to what JSON can represent: nil and
+// empty slices or maps are equal (omitempty drops both), and unexported fields are ignored.
+func equalJSONValues(a, b reflect.Value) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equalJSONValues(a.Elem(), b.Elem())
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := range a.Len() {
+			if !equalJSONValues(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		iter := a.MapRange()
+		for iter.Next() {
+			other := b.MapIndex(iter.Key())
+			if !other.IsValid() || !equalJSONValues(iter.Value(), other) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := range a.NumField() {
+			field := a.Type().Field(i)
+			if !field.IsExported() || field.Tag.Get("json") == "-" {
+				continue
+			}
+			if !equalJSONValues(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a.Equal(b)
+	}
+}
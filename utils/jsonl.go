@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// JSONLinesWriter writes one compact JSON value per line (JSON Lines). Like
+// SaveDictToJSON it does not escape HTML characters.
+type JSONLinesWriter struct {
+	f      *os.File
+	w      *bufio.Writer
+	buf    bytes.Buffer
+	enc    *json.Encoder
+	verify bool
+	path   string
+	lines  [][sha256.Size]byte // hashes of the written lines, in verify mode
+	closed bool
+}
+
+// CreateJSONLines creates the file at filePath. With verify set, like VerifyJSONFile,
+// every value is decoded again after encoding and must match the original, and Close
+// re-reads the file and checks that it holds exactly the lines that were written.
+func CreateJSONLines(filePath string, verify bool) (*JSONLinesWriter, error) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("create file error: %w", err)
+	}
+
+	w := &JSONLinesWriter{f: f, w: bufio.NewWriter(f), verify: verify, path: filePath}
+	w.enc = json.NewEncoder(&w.buf)
+	w.enc.SetEscapeHTML(false)
+	return w, nil
+}
+
+// Write appends one value as a line.
+func (w *JSONLinesWriter) Write(data interface{}) error {
+	w.buf.Reset()
+	if err := w.enc.Encode(data); err != nil {
+		return fmt.Errorf("json marshal error: %w", err)
+	}
+	if w.verify {
+		if err := verifyJSONBytes(w.buf.Bytes(), data); err != nil {
+			return fmt.Errorf("verify %s: %w", w.path, err)
+		}
+		w.lines = append(w.lines, sha256.Sum256(w.buf.Bytes()))
+	}
+	if _, err := w.w.Write(w.buf.Bytes()); err != nil {
+		return fmt.Errorf("write file error: %w", err)
+	}
+	return nil
+}
+
+// Close flushes buffered lines and closes the file. A failed flush or close can
+// mean the data never reached the disk, so both are reported. Closing twice is a no-op.
+func (w *JSONLinesWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	flushErr := w.w.Flush()
+	closeErr := w.f.Close()
+	if flushErr != nil {
+		return fmt.Errorf("write file error: %w", flushErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("close file error: %w", closeErr)
+	}
+	if w.verify {
+		return w.verifyFile()
+	}
+	return nil
+}
+
+// verifyFile streams the closed file back line by line and compares every line with
+// the hash of the line that was written. Write has checked that those decode to the
+// values passed to it, so the file on disk does too.
+func (w *JSONLinesWriter) verifyFile() error {
+	f, err := os.Open(w.path)
+	if err != nil {
+		return fmt.Errorf("verify %s: %w", w.path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	count := 0
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			if count >= len(w.lines) || sha256.Sum256(line) != w.lines[count] {
+				return fmt.Errorf("verify %s: line %d is not the line that was written", w.path, count+1)
+			}
+			count++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("verify %s: %w", w.path, err)
+		}
+	}
+	if count != len(w.lines) {
+		return fmt.Errorf("verify %s: %d lines were written but the file has %d", w.path, len(w.lines), count)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+)
+
+// JSONSchema returns a JSON Schema (draft 2020-12) of the JSON encoding of v's type,
+// built by reflection from its struct fields and json tags. Fields without omitempty
+// are required, "description" struct tags become descriptions, and nested structs are
+// listed once under $defs and referenced by name.
+func JSONSchema(v any, title string) map[string]any {
+	defs := make(map[string]any)
+	schema := jsonSchemaOf(reflect.TypeOf(v), defs)
+	if ref, ok := schema["$ref"].(string); ok {
+		// Inline the root type instead of referencing it
+		name := strings.TrimPrefix(ref, "#/$defs/")
+		schema = defs[name].(map[string]any)
+		delete(defs, name)
+	}
+
+	root := map[string]any{"$schema": "https://json-schema.org/draft/2020-12/schema", "title": title}
+	for k, val := range schema {
+		root[k] = val
+	}
+	if len(defs) > 0 {
+		root["$defs"] = defs
+	}
+	return root
+}
+
+// jsonSchemaOf returns the schema of t, adding the structs it uses to defs.
+func jsonSchemaOf(t reflect.Type, defs map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonSchemaOf(t.Elem(), defs)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchemaOf(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaOf(t.Elem(), defs)}
+	case reflect.Struct:
+		ref := map[string]any{"$ref": "#/$defs/" + t.Name()}
+		if _, ok := defs[t.Name()]; ok {
+			return ref
+		}
+		defs[t.Name()] = nil // placeholder against recursive types
+
+		properties := make(map[string]any)
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			property := jsonSchemaOf(field.Type, defs)
+			if description := field.Tag.Get("description"); description != "" {
+				// Draft 2020-12 allows keywords next to $ref
+				property["description"] = description
+			}
+			properties[name] = property
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		defs[t.Name()] = map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+		return ref
+	default:
+		return map[string]any{}
+	}
+}
@@ -31,6 +31,20 @@ func ConvertAllPolachkaLookingLettersTo1InCircassianWords(str string) string {
 	return str
 }
 
+// Patterns for the digit "1" standing for palochka next to a Cyrillic letter.
+var cyrillicThenOne = regexp.MustCompile(`(\p{Cyrillic})1`)
+var oneThenCyrillic = regexp.MustCompile(`1(\p{Cyrillic})`)
+
+// ConvertCircassian1ToPalochka reverses ConvertAllPolachkaLookingLettersTo1InCircassianWords
+// for display: a "1" after a Cyrillic letter, or else before one, becomes the palochka
+// Ӏ (U+04C0). Like the forward conversion it makes a single pass, so the homograph
+// number of "тхылъ11" stays a digit ("тхылъӀ1"), and digits elsewhere, e.g. in "1991",
+// are kept.
+func ConvertCircassian1ToPalochka(str string) string {
+	str = cyrillicThenOne.ReplaceAllString(str, "${1}Ӏ")
+	return oneThenCyrillic.ReplaceAllString(str, "Ӏ${1}")
+}
+
 // IsFullyCapitalized checks if all letter characters in the string are uppercase.
 // Non-letter characters (digits, punctuation) are ignored.
 // Returns false for empty strings or strings with no letters.
@@ -78,3 +92,20 @@ func RemoveSuffixes(s string) string {
 func StripZeroWidthChars(s string) string {
 	return strings.Trim(s, "\u200B\uFEFF\u200D\u200C")
 }
+
+// NormalizePhrase returns the search form of a phrase: lowercase, palochka-looking
+// letters converted to "1", punctuation dropped (hyphens only inside words) and
+// whitespace collapsed to single spaces.
+func NormalizePhrase(s string) string {
+	s = ConvertAllPolachkaLookingLettersTo1InCircassianWords(strings.ToLower(s))
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field = strings.Trim(field, "-"); field != "" {
+			words = append(words, field)
+		}
+	}
+	return strings.Join(words, " ")
+}
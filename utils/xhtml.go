@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// htmlTag matches a start, end or self-closing tag.
+var htmlTag = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:\s+[^<>]*?)?)\s*(/?)>`)
+
+// htmlAttribute matches one attribute of a start tag, with a double-quoted,
+// single-quoted, unquoted or missing value.
+var htmlAttribute = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+
+// htmlVoidElements never have content or an end tag.
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// HTMLToXHTML rewrites an HTML fragment as well-formed XML, for formats that embed
+// definitions in XML documents (Kindle, XDXF, TEI, Apple Dictionary):
+//   - tag and attribute names are lowercased and attribute values quoted
+//   - void elements such as <br> are self-closed
+//   - end tags without a start tag are dropped and unclosed tags are closed
+//   - HTML entities are decoded and text is re-escaped with the XML entities only
+func HTMLToXHTML(fragment string) string {
+	var sb strings.Builder
+	var open []string
+
+	writeText := func(text string) {
+		sb.WriteString(EscapeXML(html.UnescapeString(text)))
+	}
+
+	rest := fragment
+	for {
+		loc := htmlTag.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			writeText(rest)
+			break
+		}
+		writeText(rest[:loc[0]])
+
+		closing := loc[3] > loc[2]
+		name := strings.ToLower(rest[loc[4]:loc[5]])
+		attributes := rest[loc[6]:loc[7]]
+		selfClosing := loc[9] > loc[8] || htmlVoidElements[name]
+		rest = rest[loc[1]:]
+
+		switch {
+		case closing:
+			// Close everything opened after the matching start tag; drop a stray end tag
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == name {
+					for j := len(open) - 1; j >= i; j-- {
+						sb.WriteString("</" + open[j] + ">")
+					}
+					open = open[:i]
+					break
+				}
+			}
+		default:
+			sb.WriteString("<" + name)
+			for _, m := range htmlAttribute.FindAllStringSubmatch(attributes, -1) {
+				value := m[1] // a boolean attribute gets its name as value
+				if strings.Contains(m[0], "=") {
+					value = m[2] + m[3] + m[4]
+				}
+				sb.WriteString(" " + strings.ToLower(m[1]) + `="` + EscapeXML(html.UnescapeString(value)) + `"`)
+			}
+			if selfClosing {
+				sb.WriteString("/>")
+			} else {
+				sb.WriteString(">")
+				open = append(open, name)
+			}
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		sb.WriteString("</" + open[i] + ">")
+	}
+	return sb.String()
+}
+
+// HTMLBlockElements are the HTML elements that start a new line of text. The text
+// and the XDXF and DSL exports all split fragments on them.
+var HTMLBlockElements = map[string]bool{
+	"div": true, "p": true, "li": true, "tr": true, "br": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// HTMLToTextLines returns the text of an HTML fragment, one line per block element
+// (div, p, heading, list item, br), with whitespace collapsed and empty lines dropped.
+func HTMLToTextLines(fragment string) []string {
+	var lines []string
+	var current strings.Builder
+	flush := func() {
+		if line := strings.Join(strings.Fields(current.String()), " "); line != "" {
+			lines = append(lines, line)
+		}
+		current.Reset()
+	}
+
+	rest := fragment
+	for {
+		loc := htmlTag.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			current.WriteString(html.UnescapeString(rest))
+			break
+		}
+		current.WriteString(html.UnescapeString(rest[:loc[0]]))
+		if HTMLBlockElements[strings.ToLower(rest[loc[4]:loc[5]])] {
+			flush()
+		}
+		rest = rest[loc[1]:]
+	}
+	flush()
+	return lines
+}
+
+// xmlEscaper escapes the characters that are special in XML text and attribute values.
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;")
+
+// EscapeXML escapes s for use as XML text or as a quoted attribute value.
+func EscapeXML(s string) string {
+	return xmlEscaper.Replace(s)
+}